@@ -7,6 +7,7 @@ import (
 	"sync"
 	"syscall"
 
+	"memkv/internal/config"
 	"memkv/internal/server"
 )
 
@@ -18,6 +19,27 @@ var (
 func init() {
 	flag.StringVar(&host, "host", "0.0.0.0", "host")
 	flag.IntVar(&port, "port", 6379, "port")
+	flag.IntVar(&config.Hz, "hz", config.Hz, "frequency of the background tasks, between 1 and 500")
+	flag.IntVar(&config.ClientOutputBufferHardLimit, "client-output-buffer-hard-limit", config.ClientOutputBufferHardLimit,
+		"disconnect clients whose pending output exceeds this many bytes, 0 to disable")
+	flag.IntVar(&config.ClientOutputBufferSoftLimit, "client-output-buffer-soft-limit", config.ClientOutputBufferSoftLimit,
+		"disconnect clients whose pending output stays above this many bytes for soft-seconds, 0 to disable")
+	flag.IntVar(&config.ClientOutputBufferSoftSeconds, "client-output-buffer-soft-seconds", config.ClientOutputBufferSoftSeconds,
+		"how long a client may stay above the soft output limit")
+	flag.IntVar(&config.ClientQueryBufferLimit, "client-query-buffer-limit", config.ClientQueryBufferLimit,
+		"disconnect clients whose pending input exceeds this many bytes")
+	flag.IntVar(&config.ZSetMaxListpackEntries, "zset-max-listpack-entries", config.ZSetMaxListpackEntries,
+		"largest number of elements of a sorted set stored as a listpack")
+	flag.IntVar(&config.ZSetMaxListpackValue, "zset-max-listpack-value", config.ZSetMaxListpackValue,
+		"longest member, in bytes, of a sorted set stored as a listpack")
+	flag.IntVar(&config.HashMaxListpackEntries, "hash-max-listpack-entries", config.HashMaxListpackEntries,
+		"largest number of fields of a hash stored as a listpack")
+	flag.IntVar(&config.HashMaxListpackValue, "hash-max-listpack-value", config.HashMaxListpackValue,
+		"longest field or value, in bytes, of a hash stored as a listpack")
+	flag.IntVar(&config.SetMaxIntsetEntries, "set-max-intset-entries", config.SetMaxIntsetEntries,
+		"largest number of members of a set of integers stored as an intset")
+	flag.IntVar(&config.ListMaxListpackSize, "list-max-listpack-size", config.ListMaxListpackSize,
+		"elements per list node when positive, -1 to -5 for nodes of at most 4 to 64 KB")
 	flag.Parse()
 }
 
@@ -31,7 +53,7 @@ func main() {
 	wg.Add(2)
 
 	go s.RunAsyncTCPServer(&wg)
-	go server.WaitForSignal(&wg, signals)
+	go s.WaitForSignal(&wg, signals)
 
 	wg.Wait()
 }
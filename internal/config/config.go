@@ -0,0 +1,40 @@
+package config
+
+// Tunables of the server. Defaults match Redis and can be overridden from the command line.
+var (
+	// Hz is how many times per second the server runs its periodic background tasks
+	// such as active expiry.
+	Hz = 10
+
+	// ClientOutputBufferHardLimit disconnects a client as soon as its pending replies exceed
+	// this many bytes. 0 disables the limit.
+	ClientOutputBufferHardLimit = 0
+	// ClientOutputBufferSoftLimit disconnects a client whose pending replies stay above this
+	// many bytes for longer than ClientOutputBufferSoftSeconds. 0 disables the limit.
+	ClientOutputBufferSoftLimit   = 0
+	ClientOutputBufferSoftSeconds = 0
+
+	// ClientQueryBufferLimit disconnects a client whose received but not yet executed input
+	// exceeds this many bytes.
+	ClientQueryBufferLimit = 1024 * 1024 * 1024
+
+	// ZSetMaxListpackEntries and ZSetMaxListpackValue are the largest number of elements and
+	// the longest member a sorted set can have while it is stored in the compact listpack
+	// encoding, past them it is converted to a skiplist.
+	ZSetMaxListpackEntries = 128
+	ZSetMaxListpackValue   = 64
+
+	// HashMaxListpackEntries and HashMaxListpackValue are the largest number of fields and
+	// the longest field or value a hash can have while it is stored in the compact listpack
+	// encoding, past them it is converted to a hash table.
+	HashMaxListpackEntries = 128
+	HashMaxListpackValue   = 64
+
+	// SetMaxIntsetEntries is the largest number of members of a set of integers stored in
+	// the compact intset encoding, past it the set is converted to a hash table.
+	SetMaxIntsetEntries = 512
+
+	// ListMaxListpackSize limits the nodes of a list: a positive value is the largest number
+	// of elements per node, -1 to -5 limit the size of a node to 4, 8, 16, 32 or 64 KB.
+	ListMaxListpackSize = -2
+)
@@ -8,6 +8,10 @@ const (
 	ResponseInvalidValue           = "ERR invalid value"
 	ResponseInvalidPattern         = "ERR invalid pattern"
 	ResponseWrongNumberOfArguments = "ERR wrong number of arguments"
+	ResponseWrongType              = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	ResponseNoSuchKey              = "ERR no such key"
+	ResponseSyntaxError            = "ERR syntax error"
+	ResponseNotInteger             = "ERR value is not an integer or out of range"
 )
 
 const (
@@ -21,5 +25,6 @@ var RespOk = []byte("+OK\r\n")
 var RespZero = []byte(":0\r\n")
 var RespOne = []byte(":1\r\n")
 var RespEmptyArray = []byte("*0\r\n")
+var RespNilArray = []byte("*-1\r\n")
 var TtlKeyNotExist = []byte(":-2\r\n")
 var TtlKeyExistNoExpire = []byte(":-1\r\n")
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Blocking commands (BZPOPMIN, BLPOP...) that cannot be served right away park the client
+// here instead of replying. Commands that add data to a key signal it as ready and, after
+// every command, the server calls HandleClientsBlockedOnKeys to serve the clients waiting
+// on the ready keys in the order they blocked.
+
+// blockedClient is a client waiting for one of its keys to become ready
+type blockedClient struct {
+	w    io.Writer // where the reply goes once the client is served
+	keys []string
+	// elements are the positions of the client in the waiting queue of each key
+	elements map[string]*list.Element
+	deadline time.Time // zero means no timeout
+	// serve runs the blocked command against a ready key. It returns the reply, or nil
+	// when the key still cannot serve the client.
+	serve        func(key string) []byte
+	timeoutReply []byte
+}
+
+var (
+	// blockingKeys maps a key to the FIFO of the clients waiting on it
+	blockingKeys map[string]*list.List
+	// blockedClients maps a blocked client to its state
+	blockedClients map[io.Writer]*blockedClient
+	// readyKeys are the keys that received data while clients were waiting on them
+	readyKeys    []string
+	readyKeysSet map[string]struct{}
+)
+
+var (
+	errTimeoutNotFloat = errors.New("ERR timeout is not a float or out of range")
+	errTimeoutNegative = errors.New("ERR timeout is negative")
+)
+
+func init() {
+	blockingKeys = make(map[string]*list.List)
+	blockedClients = make(map[io.Writer]*blockedClient)
+	readyKeysSet = make(map[string]struct{})
+}
+
+// parseTimeout parses the timeout of a blocking command, in seconds. 0 blocks forever and
+// is returned as the zero time.
+func parseTimeout(arg string) (time.Time, error) {
+	timeout, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return time.Time{}, errTimeoutNotFloat
+	}
+	if timeout < 0 {
+		return time.Time{}, errTimeoutNegative
+	}
+	if timeout == 0 {
+		return time.Time{}, nil
+	}
+	return time.Now().Add(time.Duration(timeout * float64(time.Second))), nil
+}
+
+// serveOrBlock tries serve on each key in order and returns the first reply. When no key
+// can serve the client, it is blocked on all of them and nil is returned: the reply is
+// written to w later, when a key becomes ready, or timeoutReply once the deadline passes.
+func serveOrBlock(w io.Writer, keys []string, deadline time.Time, timeoutReply []byte,
+	serve func(key string) []byte) []byte {
+	for _, key := range keys {
+		if res := serve(key); res != nil {
+			return res
+		}
+	}
+
+	bc := &blockedClient{
+		w:            w,
+		keys:         keys,
+		elements:     make(map[string]*list.Element, len(keys)),
+		deadline:     deadline,
+		serve:        serve,
+		timeoutReply: timeoutReply,
+	}
+	for _, key := range keys {
+		if _, ok := bc.elements[key]; ok {
+			continue
+		}
+		l, ok := blockingKeys[key]
+		if !ok {
+			l = list.New()
+			blockingKeys[key] = l
+		}
+		bc.elements[key] = l.PushBack(bc)
+	}
+	blockedClients[w] = bc
+	return nil
+}
+
+// signalKeyAsReady records that key received data, the clients blocked on it are served
+// by the next HandleClientsBlockedOnKeys
+func signalKeyAsReady(key string) {
+	if _, ok := blockingKeys[key]; !ok {
+		return
+	}
+	if _, ok := readyKeysSet[key]; ok {
+		return
+	}
+	readyKeysSet[key] = struct{}{}
+	readyKeys = append(readyKeys, key)
+}
+
+// IsBlocked tells whether the client is waiting on keys
+func IsBlocked(w io.Writer) bool {
+	_, ok := blockedClients[w]
+	return ok
+}
+
+// BlockedDeadline returns when the blocked client times out, false if it waits forever
+func BlockedDeadline(w io.Writer) (time.Time, bool) {
+	bc, ok := blockedClients[w]
+	if !ok || bc.deadline.IsZero() {
+		return time.Time{}, false
+	}
+	return bc.deadline, true
+}
+
+// UnblockClient removes the client from every waiting queue without replying, e.g.
+// because it disconnected
+func UnblockClient(w io.Writer) {
+	bc, ok := blockedClients[w]
+	if !ok {
+		return
+	}
+	delete(blockedClients, w)
+	for key, e := range bc.elements {
+		l := blockingKeys[key]
+		l.Remove(e)
+		if l.Len() == 0 {
+			delete(blockingKeys, key)
+		}
+	}
+}
+
+// UnblockClientOnTimeout replies to a blocked client that its timeout elapsed and unblocks
+// it. It reports whether the client was still blocked.
+func UnblockClientOnTimeout(w io.Writer) bool {
+	bc, ok := blockedClients[w]
+	if !ok {
+		return false
+	}
+	UnblockClient(w)
+	bc.w.Write(bc.timeoutReply)
+	return true
+}
+
+// HandleClientsBlockedOnKeys serves the clients blocked on the keys that became ready, in
+// the order they blocked, and returns the clients it unblocked. Serving a client can make
+// more keys ready, e.g. when popping from a list pushes to another one, so this runs until
+// no key is left.
+func HandleClientsBlockedOnKeys() []io.Writer {
+	var served []io.Writer
+	for len(readyKeys) > 0 {
+		keys := readyKeys
+		readyKeys = nil
+		readyKeysSet = make(map[string]struct{})
+
+		for _, key := range keys {
+			l, ok := blockingKeys[key]
+			if !ok {
+				continue
+			}
+			var next *list.Element
+			for e := l.Front(); e != nil; e = next {
+				next = e.Next()
+				bc := e.Value.(*blockedClient)
+				res := bc.serve(key)
+				if res == nil {
+					// the key is empty again or holds another type now
+					continue
+				}
+				UnblockClient(bc.w)
+				bc.w.Write(res)
+				served = append(served, bc.w)
+				if lookupKey(key) == nil {
+					break
+				}
+			}
+		}
+	}
+	return served
+}
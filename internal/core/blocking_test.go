@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocking_FIFOWakeup(t *testing.T) {
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Nil(t, cmdBZPOPMIN([]string{"bq", "0"}, readWriter{first}))
+	assert.Nil(t, cmdBZPOPMIN([]string{"bq", "0"}, readWriter{second}))
+	assert.True(t, IsBlocked(readWriter{first}))
+	assert.True(t, IsBlocked(readWriter{second}))
+
+	cmdZADD([]string{"bq", "1", "a"})
+	served := HandleClientsBlockedOnKeys()
+	assert.EqualValues(t, []io.Writer{readWriter{first}}, served)
+	assert.EqualValues(t, "*3\r\n$2\r\nbq\r\n$1\r\na\r\n$1\r\n1\r\n", first.String())
+	assert.Empty(t, second.String())
+	assert.Nil(t, lookupKey("bq"))
+
+	cmdZADD([]string{"bq", "2", "b"})
+	served = HandleClientsBlockedOnKeys()
+	assert.EqualValues(t, []io.Writer{readWriter{second}}, served)
+	assert.False(t, IsBlocked(readWriter{second}))
+	assert.Empty(t, blockingKeys)
+}
+
+func TestBlocking_TimeoutAndDisconnect(t *testing.T) {
+	timedOut, gone := &bytes.Buffer{}, &bytes.Buffer{}
+
+	cmdBZPOPMAX([]string{"bq1", "bq2", "0.5"}, readWriter{timedOut})
+	deadline, ok := BlockedDeadline(readWriter{timedOut})
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(500*time.Millisecond), deadline, 100*time.Millisecond)
+	assert.True(t, UnblockClientOnTimeout(readWriter{timedOut}))
+	assert.EqualValues(t, "*-1\r\n", timedOut.String())
+	assert.False(t, UnblockClientOnTimeout(readWriter{timedOut}))
+
+	cmdBZPOPMAX([]string{"bq1", "0"}, readWriter{gone})
+	UnblockClient(readWriter{gone})
+	cmdZADD([]string{"bq1", "1", "a"})
+	assert.Empty(t, HandleClientsBlockedOnKeys())
+	assert.Empty(t, gone.String())
+	assert.Empty(t, blockingKeys)
+	deleteKey("bq1")
+}
+
+func TestBlocking_ListChain(t *testing.T) {
+	mover, popper := &bytes.Buffer{}, &bytes.Buffer{}
+
+	// the element moved to lq2 by BLMOVE wakes up the client blocked on lq2
+	assert.Nil(t, cmdBLMOVE([]string{"lq1", "lq2", "RIGHT", "LEFT", "0"}, readWriter{mover}))
+	assert.Nil(t, cmdBLPOP([]string{"lq3", "lq2", "0"}, readWriter{popper}))
+
+	cmdRPUSH([]string{"lq1", "a", "b"})
+	served := HandleClientsBlockedOnKeys()
+	assert.EqualValues(t, []io.Writer{readWriter{mover}, readWriter{popper}}, served)
+	assert.EqualValues(t, "$1\r\nb\r\n", mover.String())
+	assert.EqualValues(t, "*2\r\n$3\r\nlq2\r\n$1\r\nb\r\n", popper.String())
+	assert.Nil(t, lookupKey("lq2"))
+	assert.Empty(t, blockingKeys)
+
+	// served right away, without blocking
+	popper.Reset()
+	assert.EqualValues(t, "*2\r\n$3\r\nlq1\r\n*1\r\n$1\r\na\r\n",
+		cmdBLMPOP([]string{"0", "2", "lq3", "lq1", "LEFT", "COUNT", "5"}, readWriter{popper}))
+	assert.Nil(t, lookupKey("lq1"))
+}
+
+func TestBlocking_ListTimeoutAndWrongType(t *testing.T) {
+	timedOut := &bytes.Buffer{}
+
+	cmdBRPOPLPUSH([]string{"lq1", "lq2", "0.1"}, readWriter{timedOut})
+	assert.True(t, UnblockClientOnTimeout(readWriter{timedOut}))
+	assert.EqualValues(t, "$-1\r\n", timedOut.String())
+
+	cmdZADD([]string{"lz", "1", "a"})
+	wrongType := "-" + ErrWrongType.Error() + "\r\n"
+	assert.EqualValues(t, wrongType, string(cmdBRPOP([]string{"lq1", "lz", "0"}, readWriter{timedOut})))
+	assert.False(t, IsBlocked(readWriter{timedOut}))
+
+	// a destination of the wrong type fails the blocked client when the source gets data
+	timedOut.Reset()
+	assert.Nil(t, cmdBLMOVE([]string{"lq1", "lz", "LEFT", "LEFT", "0"}, readWriter{timedOut}))
+	cmdLPUSH([]string{"lq1", "a"})
+	HandleClientsBlockedOnKeys()
+	assert.EqualValues(t, wrongType, timedOut.String())
+	// the element was not popped
+	assert.EqualValues(t, ":1\r\n", string(cmdLLEN([]string{"lq1"})))
+	deleteKey("lq1")
+	deleteKey("lz")
+}
+
+// readWriter lets a buffer stand for a client connection
+type readWriter struct {
+	*bytes.Buffer
+}
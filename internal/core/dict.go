@@ -0,0 +1,342 @@
+package core
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+	"time"
+)
+
+// Dict maps keys to the objects stored in the keyspace. It is a chained hash table whose
+// size is a power of two. Growing or shrinking it allocates a second table and the
+// buckets are moved incrementally, a few on every access and some more from the server
+// cron, so a resize never stops the event loop for the time needed to move every key.
+type Dict struct {
+	// tables[1] is only allocated while the entries of tables[0] are moved to it
+	tables [2]dictTable
+	// rehashIdx is the next bucket of tables[0] to move, -1 when not rehashing
+	rehashIdx int
+	// pauseRehash is non zero while ForEach walks the tables, moving buckets would make it
+	// miss or repeat entries
+	pauseRehash int
+}
+
+type dictTable struct {
+	buckets []*dictEntry
+	used    int
+}
+
+type dictEntry struct {
+	key   string
+	value *Obj
+	next  *dictEntry
+}
+
+const (
+	dictInitialSize = 4
+	// dictShrinkRatio is how empty a table must be, used*dictShrinkRatio < size, to be shrunk
+	dictShrinkRatio = 8
+	// dictRehashEmptyVisits bounds the empty buckets one rehash step may go through
+	dictRehashEmptyVisits = 10
+)
+
+var dictSeed = maphash.MakeSeed()
+
+func CreateDict() *Dict {
+	return &Dict{rehashIdx: -1}
+}
+
+func dictHash(key string) uint64 {
+	return maphash.String(dictSeed, key)
+}
+
+func (t *dictTable) mask() uint64 {
+	return uint64(len(t.buckets) - 1)
+}
+
+func (d *Dict) isRehashing() bool {
+	return d.rehashIdx != -1
+}
+
+func (d *Dict) find(key string) *dictEntry {
+	if d.Len() == 0 {
+		return nil
+	}
+	h := dictHash(key)
+	for i := range d.tables {
+		t := &d.tables[i]
+		if len(t.buckets) == 0 {
+			continue
+		}
+		for e := t.buckets[h&t.mask()]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+		if !d.isRehashing() {
+			break
+		}
+	}
+	return nil
+}
+
+func (d *Dict) Get(key string) (*Obj, bool) {
+	d.rehashStep()
+	e := d.find(key)
+	if e == nil {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (d *Dict) Set(key string, obj *Obj) {
+	d.rehashStep()
+	if e := d.find(key); e != nil {
+		e.value = obj
+		return
+	}
+	d.expandIfNeeded()
+	// while rehashing new entries go to the new table, the old one only gets emptier
+	t := &d.tables[0]
+	if d.isRehashing() {
+		t = &d.tables[1]
+	}
+	idx := dictHash(key) & t.mask()
+	t.buckets[idx] = &dictEntry{key: key, value: obj, next: t.buckets[idx]}
+	t.used++
+}
+
+// Delete removes key and reports whether it existed
+func (d *Dict) Delete(key string) bool {
+	if d.Len() == 0 {
+		return false
+	}
+	d.rehashStep()
+	h := dictHash(key)
+	for i := range d.tables {
+		t := &d.tables[i]
+		if len(t.buckets) == 0 {
+			continue
+		}
+		idx := h & t.mask()
+		var prev *dictEntry
+		for e := t.buckets[idx]; e != nil; prev, e = e, e.next {
+			if e.key != key {
+				continue
+			}
+			if prev == nil {
+				t.buckets[idx] = e.next
+			} else {
+				prev.next = e.next
+			}
+			t.used--
+			d.shrinkIfNeeded()
+			return true
+		}
+		if !d.isRehashing() {
+			break
+		}
+	}
+	return false
+}
+
+func (d *Dict) Len() int {
+	return d.tables[0].used + d.tables[1].used
+}
+
+// RandomKey returns a random key, false if the dict is empty. Keys in long chains are a
+// bit less likely to be picked than others, like in Redis.
+func (d *Dict) RandomKey() (string, bool) {
+	if d.Len() == 0 {
+		return "", false
+	}
+	d.rehashStep()
+	var e *dictEntry
+	if d.isRehashing() {
+		// the buckets of the old table before rehashIdx are empty, skip them
+		size0 := len(d.tables[0].buckets)
+		slots := size0 + len(d.tables[1].buckets) - d.rehashIdx
+		for e == nil {
+			idx := d.rehashIdx + rand.Intn(slots)
+			if idx >= size0 {
+				e = d.tables[1].buckets[idx-size0]
+			} else {
+				e = d.tables[0].buckets[idx]
+			}
+		}
+	} else {
+		for e == nil {
+			e = d.tables[0].buckets[rand.Intn(len(d.tables[0].buckets))]
+		}
+	}
+
+	chainLen := 0
+	for x := e; x != nil; x = x.next {
+		chainLen++
+	}
+	for i := rand.Intn(chainLen); i > 0; i-- {
+		e = e.next
+	}
+	return e.key, true
+}
+
+// ForEach calls fn for every entry until it returns false. fn may delete entries, including
+// the current one, but must not add any.
+func (d *Dict) ForEach(fn func(key string, obj *Obj) bool) {
+	d.pauseRehash++
+	defer func() { d.pauseRehash-- }()
+	for i := range d.tables {
+		t := &d.tables[i]
+		for idx := range t.buckets {
+			var next *dictEntry
+			for e := t.buckets[idx]; e != nil; e = next {
+				next = e.next
+				if !fn(e.key, e.value) {
+					return
+				}
+			}
+		}
+		if !d.isRehashing() {
+			break
+		}
+	}
+}
+
+// Scan calls fn for the entries of one bucket and returns the cursor of the next call, 0
+// once the whole table was visited. Starting from 0, every key present for the whole
+// iteration is returned at least once, even if the table is resized in between.
+//
+// The cursor is incremented from its highest bit down, i.e. the bits are reversed, added
+// one and reversed again. A bucket at index i of a table of size n is split into i and
+// i+n when the table doubles, and both come after i in this order, so resizing never makes
+// a cursor skip a bucket that was not visited yet. Shrinking merges buckets that can
+// already have been partly visited, which only causes repetitions.
+func (d *Dict) Scan(cursor uint64, fn func(key string, obj *Obj)) uint64 {
+	if d.Len() == 0 {
+		return 0
+	}
+	emit := func(t *dictTable, idx uint64) {
+		for e := t.buckets[idx]; e != nil; e = e.next {
+			fn(e.key, e.value)
+		}
+	}
+
+	if !d.isRehashing() {
+		t := &d.tables[0]
+		emit(t, cursor&t.mask())
+		return nextScanCursor(cursor, t.mask())
+	}
+
+	small, large := &d.tables[0], &d.tables[1]
+	if len(small.buckets) > len(large.buckets) {
+		small, large = large, small
+	}
+	emit(small, cursor&small.mask())
+	// visit every bucket of the larger table that the bucket of the smaller one expands to
+	for {
+		emit(large, cursor&large.mask())
+		cursor = nextScanCursor(cursor, large.mask())
+		if cursor&(small.mask()^large.mask()) == 0 {
+			break
+		}
+	}
+	return cursor
+}
+
+// nextScanCursor increments the bits of cursor covered by mask in reverse order
+func nextScanCursor(cursor uint64, mask uint64) uint64 {
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// RehashFor moves buckets to the new table for about timeLimit, starting to shrink the
+// table first if deletions left it mostly empty. It reports whether the rehashing is still
+// in progress.
+func (d *Dict) RehashFor(timeLimit time.Duration) bool {
+	start := time.Now()
+	d.shrinkIfNeeded()
+	for d.rehash(100) {
+		if time.Since(start) > timeLimit {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dict) rehashStep() {
+	if d.pauseRehash == 0 {
+		d.rehash(1)
+	}
+}
+
+// rehash moves n buckets of the old table to the new one and reports whether there are
+// more to move
+func (d *Dict) rehash(n int) bool {
+	if !d.isRehashing() {
+		return false
+	}
+	from, to := &d.tables[0], &d.tables[1]
+	emptyVisits := n * dictRehashEmptyVisits
+	for ; n > 0 && from.used > 0; n-- {
+		for from.buckets[d.rehashIdx] == nil {
+			d.rehashIdx++
+			if emptyVisits--; emptyVisits == 0 {
+				return true
+			}
+		}
+		var next *dictEntry
+		for e := from.buckets[d.rehashIdx]; e != nil; e = next {
+			next = e.next
+			idx := dictHash(e.key) & to.mask()
+			e.next = to.buckets[idx]
+			to.buckets[idx] = e
+			from.used--
+			to.used++
+		}
+		from.buckets[d.rehashIdx] = nil
+		d.rehashIdx++
+	}
+	if from.used == 0 {
+		d.tables[0] = d.tables[1]
+		d.tables[1] = dictTable{}
+		d.rehashIdx = -1
+		return false
+	}
+	return true
+}
+
+func (d *Dict) expandIfNeeded() {
+	if d.isRehashing() {
+		return
+	}
+	if len(d.tables[0].buckets) == 0 {
+		d.tables[0].buckets = make([]*dictEntry, dictInitialSize)
+		return
+	}
+	if d.tables[0].used >= len(d.tables[0].buckets) {
+		d.resize(d.tables[0].used + 1)
+	}
+}
+
+func (d *Dict) shrinkIfNeeded() {
+	size := len(d.tables[0].buckets)
+	if d.isRehashing() || size <= dictInitialSize || d.tables[0].used*dictShrinkRatio >= size {
+		return
+	}
+	d.resize(d.tables[0].used)
+}
+
+// resize starts moving the entries to a table big enough for size entries
+func (d *Dict) resize(size int) {
+	n := dictInitialSize
+	for n < size {
+		n *= 2
+	}
+	if n == len(d.tables[0].buckets) {
+		return
+	}
+	d.tables[1] = dictTable{buckets: make([]*dictEntry, n)}
+	d.rehashIdx = 0
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDict_SetGetDeleteAcrossResizes(t *testing.T) {
+	d := CreateDict()
+	for i := 0; i < 1000; i++ {
+		d.Set(fmt.Sprint(i), newStringObj(fmt.Sprint(i)))
+	}
+	assert.EqualValues(t, 1000, d.Len())
+	for i := 0; i < 1000; i++ {
+		obj, ok := d.Get(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.EqualValues(t, fmt.Sprint(i), obj.stringValue())
+	}
+
+	d.Set("1", newStringObj("one"))
+	obj, _ := d.Get("1")
+	assert.EqualValues(t, "one", obj.stringValue())
+	assert.EqualValues(t, 1000, d.Len())
+
+	for i := 0; i < 990; i++ {
+		assert.True(t, d.Delete(fmt.Sprint(i)))
+	}
+	assert.False(t, d.Delete("0"))
+	assert.EqualValues(t, 10, d.Len())
+	// the cron shrinks the table back to fit the remaining keys
+	for i := 0; i < 10 && (d.isRehashing() || len(d.tables[0].buckets) > 16); i++ {
+		d.RehashFor(time.Second)
+	}
+	assert.LessOrEqual(t, len(d.tables[0].buckets), 16)
+	_, ok := d.Get("995")
+	assert.True(t, ok)
+}
+
+func scanAll(d *Dict, between func()) map[string]int {
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		cursor = d.Scan(cursor, func(key string, _ *Obj) {
+			seen[key]++
+		})
+		if cursor == 0 {
+			return seen
+		}
+		between()
+	}
+}
+
+func TestDict_ScanCoversEveryKey(t *testing.T) {
+	d := CreateDict()
+	for i := 0; i < 500; i++ {
+		d.Set(fmt.Sprint(i), nil)
+	}
+	d.RehashFor(time.Second)
+	seen := scanAll(d, func() {})
+	assert.EqualValues(t, 500, len(seen))
+	for _, n := range seen {
+		assert.EqualValues(t, 1, n, "no key is returned twice when the table does not change")
+	}
+}
+
+func TestDict_ScanWhileGrowing(t *testing.T) {
+	d := CreateDict()
+	for i := 0; i < 100; i++ {
+		d.Set(fmt.Sprint(i), nil)
+	}
+	next := 100
+	seen := scanAll(d, func() {
+		// add keys so the table is resized several times and rehashed during the scan
+		for j := 0; j < 20 && next < 2000; j++ {
+			d.Set(fmt.Sprint(next), nil)
+			next++
+		}
+	})
+	for i := 0; i < 100; i++ {
+		assert.Contains(t, seen, fmt.Sprint(i))
+	}
+}
+
+func TestDict_ScanWhileShrinking(t *testing.T) {
+	d := CreateDict()
+	for i := 0; i < 1000; i++ {
+		d.Set(fmt.Sprint(i), nil)
+	}
+	d.RehashFor(time.Second)
+	deleted := 999
+	seen := scanAll(d, func() {
+		// delete from the keys that are not checked so the table shrinks during the scan
+		for j := 0; j < 10 && deleted >= 100; j++ {
+			d.Delete(fmt.Sprint(deleted))
+			deleted--
+		}
+	})
+	for i := 0; i < 100; i++ {
+		assert.Contains(t, seen, fmt.Sprint(i))
+	}
+}
+
+func TestDict_RandomKey(t *testing.T) {
+	d := CreateDict()
+	_, ok := d.RandomKey()
+	assert.False(t, ok)
+
+	for i := 0; i < 100; i++ {
+		d.Set(fmt.Sprint(i), nil)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 2000; i++ {
+		key, ok := d.RandomKey()
+		assert.True(t, ok)
+		seen[key] = true
+	}
+	// every key should come out with that many draws, whether the table is rehashing or not
+	assert.EqualValues(t, 100, len(seen))
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseDouble parses a score or any other double argument. NaN is refused: it compares
+// false with everything, so it would break the order of a sorted set.
+func parseDouble(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// formatDouble renders f with the fewest digits that parse back to exactly f, the way every
+// sorted set reply shows a score. Numbers are written in plain notation unless they are
+// very large or very small, and infinities are "inf" and "-inf".
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	if abs := math.Abs(f); abs != 0 && (abs >= 1e21 || abs < 1e-6) {
+		// 'e' pads the exponent to two digits, 1e-09 is written 1e-9
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		mantissa, exp, _ := strings.Cut(s, "e")
+		sign, digits := exp[:1], strings.TrimLeft(exp[1:], "0")
+		return mantissa + "e" + sign + digits
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDouble(t *testing.T) {
+	cases := map[float64]string{
+		0:                      "0",
+		math.Copysign(0, -1):   "-0",
+		1:                      "1",
+		-2.5:                   "-2.5",
+		0.1:                    "0.1",
+		1e-9:                   "1e-9",
+		0.000001:               "0.000001",
+		1e20:                   "100000000000000000000",
+		1e21:                   "1e+21",
+		1234567:                "1234567",
+		1.7976931348623157e308: "1.7976931348623157e+308",
+		math.Inf(1):            "inf",
+		math.Inf(-1):           "-inf",
+	}
+	for f, expected := range cases {
+		s := formatDouble(f)
+		assert.EqualValues(t, expected, s)
+		parsed, ok := parseDouble(s)
+		assert.True(t, ok, s)
+		assert.EqualValues(t, math.Float64bits(f), math.Float64bits(parsed), s)
+	}
+}
+
+func TestParseDouble(t *testing.T) {
+	for _, s := range []string{"1.5", "-3", "+inf", "-inf", "inf", "1e10", "0x1p-2"} {
+		_, ok := parseDouble(s)
+		assert.True(t, ok, s)
+	}
+	for _, s := range []string{"nan", "NaN", "", "abc", " 1", "1e400"} {
+		_, ok := parseDouble(s)
+		assert.False(t, ok, s)
+	}
+}
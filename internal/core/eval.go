@@ -2,7 +2,9 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 
 	"memkv/internal/constants"
 )
@@ -15,6 +17,8 @@ const (
 	CommandDel    = "DEL"
 	CommandExists = "EXISTS"
 	CommandKeys   = "KEYS"
+	CommandType   = "TYPE"
+	CommandRename = "RENAME"
 )
 
 func EvalAndResponse(cmd *MemkvCommand, c io.ReadWriter) error {
@@ -24,17 +28,288 @@ func EvalAndResponse(cmd *MemkvCommand, c io.ReadWriter) error {
 	case CommandPing:
 		res = cmdPing(cmd, c)
 
+		// Keyspace
+	case CommandKeys:
+		res = cmdKEYS(cmd.Args)
+	case "SCAN":
+		res = cmdSCAN(cmd.Args)
+	case "ZSCAN":
+		res = cmdZSCAN(cmd.Args)
+	case "HSCAN":
+		res = cmdHSCAN(cmd.Args)
+	case "SSCAN":
+		res = cmdSSCAN(cmd.Args)
+	case "OBJECT":
+		res = cmdOBJECT(cmd.Args)
+	case CommandType:
+		res = cmdTYPE(cmd.Args)
+	case CommandDel:
+		res = cmdDEL(cmd.Args)
+	case CommandExists:
+		res = cmdEXISTS(cmd.Args)
+	case "UNLINK":
+		res = cmdUNLINK(cmd.Args)
+	case CommandRename:
+		res = cmdRENAME(cmd.Args)
+	case "RENAMENX":
+		res = cmdRENAMENX(cmd.Args)
+	case "COPY":
+		res = cmdCOPY(cmd.Args)
+	case "TOUCH":
+		res = cmdTOUCH(cmd.Args)
+	case "RANDOMKEY":
+		res = cmdRANDOMKEY(cmd.Args)
+	case "DBSIZE":
+		res = cmdDBSIZE(cmd.Args)
+
+		// Expiration
+	case "EXPIRE":
+		res = cmdEXPIRE(cmd.Args)
+	case "PEXPIRE":
+		res = cmdPEXPIRE(cmd.Args)
+	case "EXPIREAT":
+		res = cmdEXPIREAT(cmd.Args)
+	case "PEXPIREAT":
+		res = cmdPEXPIREAT(cmd.Args)
+	case CommandTTL:
+		res = cmdTTL(cmd.Args)
+	case "PTTL":
+		res = cmdPTTL(cmd.Args)
+	case "EXPIRETIME":
+		res = cmdEXPIRETIME(cmd.Args)
+	case "PEXPIRETIME":
+		res = cmdPEXPIRETIME(cmd.Args)
+	case "PERSIST":
+		res = cmdPERSIST(cmd.Args)
+
+		// String
+	case CommandSet:
+		res = cmdSET(cmd.Args)
+	case CommandGet:
+		res = cmdGET(cmd.Args)
+	case "GETDEL":
+		res = cmdGETDEL(cmd.Args)
+	case "GETEX":
+		res = cmdGETEX(cmd.Args)
+	case "SETNX":
+		res = cmdSETNX(cmd.Args)
+	case "SETEX":
+		res = cmdSETEX(cmd.Args)
+	case "PSETEX":
+		res = cmdPSETEX(cmd.Args)
+	case "MGET":
+		res = cmdMGET(cmd.Args)
+	case "MSET":
+		res = cmdMSET(cmd.Args)
+	case "MSETNX":
+		res = cmdMSETNX(cmd.Args)
+
+		// List
+	case "LPUSH":
+		res = cmdLPUSH(cmd.Args)
+	case "RPUSH":
+		res = cmdRPUSH(cmd.Args)
+	case "LPUSHX":
+		res = cmdLPUSHX(cmd.Args)
+	case "RPUSHX":
+		res = cmdRPUSHX(cmd.Args)
+	case "LPOP":
+		res = cmdLPOP(cmd.Args)
+	case "RPOP":
+		res = cmdRPOP(cmd.Args)
+	case "LRANGE":
+		res = cmdLRANGE(cmd.Args)
+	case "LINDEX":
+		res = cmdLINDEX(cmd.Args)
+	case "LSET":
+		res = cmdLSET(cmd.Args)
+	case "LINSERT":
+		res = cmdLINSERT(cmd.Args)
+	case "LLEN":
+		res = cmdLLEN(cmd.Args)
+	case "LREM":
+		res = cmdLREM(cmd.Args)
+	case "LTRIM":
+		res = cmdLTRIM(cmd.Args)
+	case "LPOS":
+		res = cmdLPOS(cmd.Args)
+	case "LMOVE":
+		res = cmdLMOVE(cmd.Args)
+	case "RPOPLPUSH":
+		res = cmdRPOPLPUSH(cmd.Args)
+	case "LMPOP":
+		res = cmdLMPOP(cmd.Args)
+	case "BLPOP":
+		res = cmdBLPOP(cmd.Args, c)
+	case "BRPOP":
+		res = cmdBRPOP(cmd.Args, c)
+	case "BLMOVE":
+		res = cmdBLMOVE(cmd.Args, c)
+	case "BRPOPLPUSH":
+		res = cmdBRPOPLPUSH(cmd.Args, c)
+	case "BLMPOP":
+		res = cmdBLMPOP(cmd.Args, c)
+
+		// Hash
+	case "HSET":
+		res = cmdHSET(cmd.Args)
+	case "HMSET":
+		res = cmdHMSET(cmd.Args)
+	case "HSETNX":
+		res = cmdHSETNX(cmd.Args)
+	case "HGET":
+		res = cmdHGET(cmd.Args)
+	case "HMGET":
+		res = cmdHMGET(cmd.Args)
+	case "HDEL":
+		res = cmdHDEL(cmd.Args)
+	case "HEXISTS":
+		res = cmdHEXISTS(cmd.Args)
+	case "HLEN":
+		res = cmdHLEN(cmd.Args)
+	case "HSTRLEN":
+		res = cmdHSTRLEN(cmd.Args)
+	case "HKEYS":
+		res = cmdHKEYS(cmd.Args)
+	case "HVALS":
+		res = cmdHVALS(cmd.Args)
+	case "HGETALL":
+		res = cmdHGETALL(cmd.Args)
+	case "HINCRBY":
+		res = cmdHINCRBY(cmd.Args)
+	case "HINCRBYFLOAT":
+		res = cmdHINCRBYFLOAT(cmd.Args)
+	case "HRANDFIELD":
+		res = cmdHRANDFIELD(cmd.Args)
+	case "HEXPIRE":
+		res = cmdHEXPIRE(cmd.Args)
+	case "HPEXPIRE":
+		res = cmdHPEXPIRE(cmd.Args)
+	case "HEXPIREAT":
+		res = cmdHEXPIREAT(cmd.Args)
+	case "HPEXPIREAT":
+		res = cmdHPEXPIREAT(cmd.Args)
+	case "HTTL":
+		res = cmdHTTL(cmd.Args)
+	case "HPTTL":
+		res = cmdHPTTL(cmd.Args)
+	case "HEXPIRETIME":
+		res = cmdHEXPIRETIME(cmd.Args)
+	case "HPEXPIRETIME":
+		res = cmdHPEXPIRETIME(cmd.Args)
+	case "HPERSIST":
+		res = cmdHPERSIST(cmd.Args)
+	case "HGETEX":
+		res = cmdHGETEX(cmd.Args)
+	case "HSETEX":
+		res = cmdHSETEX(cmd.Args)
+
+		// Set
+	case "SADD":
+		res = cmdSADD(cmd.Args)
+	case "SREM":
+		res = cmdSREM(cmd.Args)
+	case "SISMEMBER":
+		res = cmdSISMEMBER(cmd.Args)
+	case "SMISMEMBER":
+		res = cmdSMISMEMBER(cmd.Args)
+	case "SMEMBERS":
+		res = cmdSMEMBERS(cmd.Args)
+	case "SCARD":
+		res = cmdSCARD(cmd.Args)
+	case "SPOP":
+		res = cmdSPOP(cmd.Args)
+	case "SRANDMEMBER":
+		res = cmdSRANDMEMBER(cmd.Args)
+	case "SMOVE":
+		res = cmdSMOVE(cmd.Args)
+	case "SINTER":
+		res = cmdSINTER(cmd.Args)
+	case "SUNION":
+		res = cmdSUNION(cmd.Args)
+	case "SDIFF":
+		res = cmdSDIFF(cmd.Args)
+	case "SINTERSTORE":
+		res = cmdSINTERSTORE(cmd.Args)
+	case "SUNIONSTORE":
+		res = cmdSUNIONSTORE(cmd.Args)
+	case "SDIFFSTORE":
+		res = cmdSDIFFSTORE(cmd.Args)
+	case "SINTERCARD":
+		res = cmdSINTERCARD(cmd.Args)
+
 		// Sorted set
 	case "ZADD":
 		res = cmdZADD(cmd.Args)
+	case "ZINCRBY":
+		res = cmdZINCRBY(cmd.Args)
 	case "ZRANK":
 		res = cmdZRANK(cmd.Args)
 	case "ZREM":
 		res = cmdZREM(cmd.Args)
+	case "ZREVRANK":
+		res = cmdZREVRANK(cmd.Args)
+	case "ZMSCORE":
+		res = cmdZMSCORE(cmd.Args)
+	case "ZRANDMEMBER":
+		res = cmdZRANDMEMBER(cmd.Args)
 	case "ZSCORE":
 		res = cmdZSCORE(cmd.Args)
 	case "ZCARD":
 		res = cmdZCARD(cmd.Args)
+	case "ZCOUNT":
+		res = cmdZCOUNT(cmd.Args)
+	case "ZLEXCOUNT":
+		res = cmdZLEXCOUNT(cmd.Args)
+	case "ZPOPMIN":
+		res = cmdZPOPMIN(cmd.Args)
+	case "ZPOPMAX":
+		res = cmdZPOPMAX(cmd.Args)
+	case "ZMPOP":
+		res = cmdZMPOP(cmd.Args)
+	case "BZPOPMIN":
+		res = cmdBZPOPMIN(cmd.Args, c)
+	case "BZPOPMAX":
+		res = cmdBZPOPMAX(cmd.Args, c)
+	case "BZMPOP":
+		res = cmdBZMPOP(cmd.Args, c)
+	case "ZUNION":
+		res = cmdZUNION(cmd.Args)
+	case "ZINTER":
+		res = cmdZINTER(cmd.Args)
+	case "ZDIFF":
+		res = cmdZDIFF(cmd.Args)
+	case "ZUNIONSTORE":
+		res = cmdZUNIONSTORE(cmd.Args)
+	case "ZINTERSTORE":
+		res = cmdZINTERSTORE(cmd.Args)
+	case "ZDIFFSTORE":
+		res = cmdZDIFFSTORE(cmd.Args)
+	case "ZINTERCARD":
+		res = cmdZINTERCARD(cmd.Args)
+	case "ZREMRANGEBYRANK":
+		res = cmdZREMRANGEBYRANK(cmd.Args)
+	case "ZREMRANGEBYSCORE":
+		res = cmdZREMRANGEBYSCORE(cmd.Args)
+	case "ZREMRANGEBYLEX":
+		res = cmdZREMRANGEBYLEX(cmd.Args)
+	case "ZRANGE":
+		res = cmdZRANGE(cmd.Args)
+	case "ZRANGESTORE":
+		res = cmdZRANGESTORE(cmd.Args)
+	case "ZREVRANGE":
+		res = cmdZREVRANGE(cmd.Args)
+	case "ZRANGEBYSCORE":
+		res = cmdZRANGEBYSCORE(cmd.Args)
+	case "ZREVRANGEBYSCORE":
+		res = cmdZREVRANGEBYSCORE(cmd.Args)
+	case "ZRANGEBYLEX":
+		res = cmdZRANGEBYLEX(cmd.Args)
+	case "ZREVRANGEBYLEX":
+		res = cmdZREVRANGEBYLEX(cmd.Args)
+	default:
+		// every command must get a reply, otherwise pipelined replies get out of step
+		res = Encode(fmt.Errorf("ERR unknown command '%s'", cmd.Cmd), false)
 	}
 
 	_, err := c.Write(res)
@@ -55,3 +330,7 @@ func cmdPing(cmd *MemkvCommand, c io.ReadWriter) []byte {
 
 	return buf
 }
+
+func respWrongNumberOfArgs(cmd string) []byte {
+	return Encode(fmt.Errorf("%s for '%s' command", constants.ResponseWrongNumberOfArguments, strings.ToLower(cmd)), false)
+}
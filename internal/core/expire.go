@@ -0,0 +1,70 @@
+package core
+
+import "time"
+
+const (
+	// activeExpireKeysPerLoop is how many keys with a time to live are sampled in one loop
+	activeExpireKeysPerLoop = 20
+	// activeExpireAcceptableStale is the percentage of expired keys in a sample under which
+	// the cycle stops, there are too few of them left to be worth the CPU time
+	activeExpireAcceptableStale = 10
+)
+
+// ActiveExpireCycle removes expired keys that nobody accesses anymore, lazy expiration
+// alone would let them sit in memory forever. It samples random keys among the ones with a
+// time to live and keeps going while a large part of the sample is expired, for at most
+// timeLimit. It returns how many keys were removed.
+func ActiveExpireCycle(timeLimit time.Duration) int {
+	start := time.Now()
+	removed := 0
+	for len(expires) > 0 {
+		sampled, expired := 0, 0
+		now := currentTimeMs()
+		// iteration over a Go map starts at a random position, which is the sampling we want
+		for key, at := range expires {
+			if sampled == activeExpireKeysPerLoop {
+				break
+			}
+			sampled++
+			if at <= now {
+				deleteKey(key)
+				expired++
+			}
+		}
+		removed += expired
+		if expired*100 <= sampled*activeExpireAcceptableStale || time.Since(start) > timeLimit {
+			break
+		}
+	}
+	return removed
+}
+
+// ActiveExpireHashFields removes the expired fields of the hashes that nobody accesses, and
+// the hashes left empty, for at most timeLimit. It returns how many fields were removed.
+func ActiveExpireHashFields(timeLimit time.Duration) int {
+	start := time.Now()
+	now := currentTimeMs()
+	removed := 0
+	// like in ActiveExpireCycle, the random starting point of the iteration spreads the work
+	// of the cycles cut by the time limit
+	for key := range hashesWithFieldExpires {
+		if time.Since(start) > timeLimit {
+			break
+		}
+		var h *Hash
+		if obj, ok := keyspace.Get(key); ok {
+			h, _ = obj.Value.(*Hash)
+		}
+		if h == nil || !h.hasFieldExpires() {
+			delete(hashesWithFieldExpires, key)
+			continue
+		}
+		removed += h.expireFields(now)
+		if h.Len() == 0 {
+			deleteKey(key)
+		} else if !h.hasFieldExpires() {
+			delete(hashesWithFieldExpires, key)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+const (
+	expireInNX = 1 << 0 // Set expiry only when the key has no expiry
+	expireInXX = 1 << 1 // Set expiry only when the key has an existing expiry
+	expireInGT = 1 << 2 // Set expiry only when the new expiry is greater than current one
+	expireInLT = 1 << 3 // Set expiry only when the new expiry is less than current one
+)
+
+// EXPIRE key seconds [NX | XX | GT | LT]
+func cmdEXPIRE(args []string) []byte {
+	return expireGeneric(args, "EXPIRE", 1000, false)
+}
+
+// PEXPIRE key milliseconds [NX | XX | GT | LT]
+func cmdPEXPIRE(args []string) []byte {
+	return expireGeneric(args, "PEXPIRE", 1, false)
+}
+
+// EXPIREAT key unix-time-seconds [NX | XX | GT | LT]
+func cmdEXPIREAT(args []string) []byte {
+	return expireGeneric(args, "EXPIREAT", 1000, true)
+}
+
+// PEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT]
+func cmdPEXPIREAT(args []string) []byte {
+	return expireGeneric(args, "PEXPIREAT", 1, true)
+}
+
+// expireGeneric implements the EXPIRE family. unit converts the argument to milliseconds,
+// absolute tells whether it is a unix time rather than a delay.
+func expireGeneric(args []string, cmdName string, unit int64, absolute bool) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	key := args[0]
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	flags, err := parseExpireFlags(args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(cmdName))
+	if n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		return Encode(errInvalid, false)
+	}
+	at := n * unit
+	if !absolute {
+		now := currentTimeMs()
+		if at > math.MaxInt64-now {
+			return Encode(errInvalid, false)
+		}
+		at += now
+	}
+
+	if lookupKey(key) == nil {
+		return constants.RespZero
+	}
+
+	cur, hasExpire := getExpire(key)
+	switch {
+	case flags&expireInNX != 0 && hasExpire,
+		flags&expireInXX != 0 && !hasExpire,
+		// a key without expiry has an infinite time to live
+		flags&expireInGT != 0 && (!hasExpire || at <= cur),
+		flags&expireInLT != 0 && hasExpire && at >= cur:
+		return constants.RespZero
+	}
+
+	if at <= currentTimeMs() {
+		// already in the past, the key is gone right away
+		deleteKey(key)
+		return constants.RespOne
+	}
+	setExpire(key, at)
+	return constants.RespOne
+}
+
+func parseExpireFlags(args []string) (int, error) {
+	flags := 0
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			flags |= expireInNX
+		case "XX":
+			flags |= expireInXX
+		case "GT":
+			flags |= expireInGT
+		case "LT":
+			flags |= expireInLT
+		default:
+			return 0, fmt.Errorf("ERR Unsupported option %s", arg)
+		}
+	}
+	if flags&expireInNX != 0 && flags&(expireInXX|expireInGT|expireInLT) != 0 {
+		return 0, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if flags&expireInGT != 0 && flags&expireInLT != 0 {
+		return 0, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+	return flags, nil
+}
+
+// TTL key
+func cmdTTL(args []string) []byte {
+	return ttlGeneric(args, CommandTTL, false, false)
+}
+
+// PTTL key
+func cmdPTTL(args []string) []byte {
+	return ttlGeneric(args, "PTTL", true, false)
+}
+
+// EXPIRETIME key
+func cmdEXPIRETIME(args []string) []byte {
+	return ttlGeneric(args, "EXPIRETIME", false, true)
+}
+
+// PEXPIRETIME key
+func cmdPEXPIRETIME(args []string) []byte {
+	return ttlGeneric(args, "PEXPIRETIME", true, true)
+}
+
+// ttlGeneric replies with the remaining time to live of a key, or its expiration time when
+// absolute is true, in milliseconds or seconds.
+func ttlGeneric(args []string, cmdName string, inMs bool, absolute bool) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	key := args[0]
+	if lookupKey(key) == nil {
+		return constants.TtlKeyNotExist
+	}
+	at, hasExpire := getExpire(key)
+	if !hasExpire {
+		return constants.TtlKeyExistNoExpire
+	}
+	if !absolute {
+		at = max(at-currentTimeMs(), 0)
+	}
+	if !inMs {
+		at = (at + 500) / 1000
+	}
+	return Encode(at, false)
+}
+
+// PERSIST key
+func cmdPERSIST(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("PERSIST")
+	}
+	if lookupKey(args[0]) == nil || !removeExpire(args[0]) {
+		return constants.RespZero
+	}
+	return constants.RespOne
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"memkv/internal/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupKey_LazyExpire(t *testing.T) {
+	setKey("lazy", newStringObj("v"))
+	setExpire("lazy", currentTimeMs()-1)
+	assert.Nil(t, lookupKey("lazy"))
+	_, hasExpire := getExpire("lazy")
+	assert.False(t, hasExpire)
+	_, ok := keyspace.Get("lazy")
+	assert.False(t, ok)
+}
+
+func TestRandomKey_BoundedTries(t *testing.T) {
+	var keys []string
+	for i := 0; i < 10*randomKeyMaxTries; i++ {
+		key := fmt.Sprintf("random-expired-%d", i)
+		setKey(key, newStringObj("v"))
+		setExpire(key, currentTimeMs()-1)
+		keys = append(keys, key)
+	}
+	defer cmdDEL(keys)
+
+	before := keyspace.Len()
+	assert.NotEqualValues(t, constants.RespNil, cmdRANDOMKEY(nil))
+	assert.LessOrEqual(t, before-keyspace.Len(), randomKeyMaxTries-1)
+}
+
+func TestActiveExpireCycle(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("expired-%d", i)
+		setKey(key, newStringObj("v"))
+		setExpire(key, currentTimeMs()-1)
+	}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("alive-%d", i)
+		setKey(key, newStringObj("v"))
+		setExpire(key, currentTimeMs()+time.Hour.Milliseconds())
+	}
+
+	removed := ActiveExpireCycle(time.Second)
+	// the cycle stops once few expired keys are left in the samples, most of them must be gone
+	assert.Greater(t, removed, 900)
+	for i := 0; i < 10; i++ {
+		assert.NotNil(t, lookupKey(fmt.Sprintf("alive-%d", i)))
+	}
+
+	for i := 0; i < 1000; i++ {
+		deleteKey(fmt.Sprintf("expired-%d", i))
+	}
+	for i := 0; i < 10; i++ {
+		deleteKey(fmt.Sprintf("alive-%d", i))
+	}
+}
+
+func TestActiveExpireHashFields(t *testing.T) {
+	now := currentTimeMs()
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("hash-%d", i)
+		h := createNumberedHash(3)
+		h.SetFieldExpire("f0", now-1)
+		if i%2 == 0 {
+			// every field expired, the key goes away
+			h.SetFieldExpire("f1", now-1)
+			h.SetFieldExpire("f2", now-1)
+		} else {
+			h.SetFieldExpire("f1", now+time.Hour.Milliseconds())
+		}
+		// setKey tracks the hashes stored with times to live, like a renamed one
+		setKey(key, newHashObj(h))
+	}
+
+	assert.EqualValues(t, 200, ActiveExpireHashFields(time.Second))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("hash-%d", i)
+		obj, ok := keyspace.Get(key)
+		if i%2 == 0 {
+			assert.False(t, ok)
+			continue
+		}
+		assert.EqualValues(t, 2, obj.Value.(*Hash).Len())
+		assert.Contains(t, hashesWithFieldExpires, key)
+		deleteKey(key)
+	}
+	assert.Empty(t, hashesWithFieldExpires)
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"errors"
+
+	"memkv/internal/constants"
+)
+
+// globMaxNesting is the largest number of '*' groups a pattern may have. Every group is a
+// level of recursion of the matcher, so this bounds its stack whatever the input.
+const globMaxNesting = 1000
+
+var errInvalidPattern = errors.New(constants.ResponseInvalidPattern)
+
+// validatePattern rejects the patterns stringMatch would refuse to run, commands taking a
+// pattern check it once before matching it against many strings
+func validatePattern(pattern string) error {
+	nesting := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*':
+			if i == 0 || pattern[i-1] != '*' {
+				nesting++
+			}
+		}
+	}
+	if nesting > globMaxNesting {
+		return errInvalidPattern
+	}
+	return nil
+}
+
+// stringMatch reports whether s matches the glob-style pattern, with the Redis syntax:
+// * matches any sequence, ? any character, [abc], [a-z] and [^a] character classes, and a
+// backslash escapes the next character. It works on bytes, like Redis. Patterns with more
+// than globMaxNesting '*' groups never match, see validatePattern.
+func stringMatch(pattern string, s string, nocase bool) bool {
+	m := globMatcher{nocase: nocase}
+	return m.match(pattern, s, 0)
+}
+
+type globMatcher struct {
+	nocase bool
+	// skipLongerMatches is set once the part of the pattern after a '*' was found to match
+	// nowhere in the rest of the string. Letting an earlier '*' consume more of the string
+	// cannot help then, and giving up right away is what keeps patterns such as
+	// "a*a*a*a*a*b" from backtracking exponentially.
+	skipLongerMatches bool
+}
+
+func (m *globMatcher) match(pattern string, s string, nesting int) bool {
+	if nesting > globMaxNesting {
+		return false
+	}
+	p, i := 0, 0
+	for p < len(pattern) && i < len(s) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; i < len(s); i++ {
+				if m.match(pattern[p+1:], s[i:], nesting+1) {
+					return true
+				}
+				if m.skipLongerMatches {
+					return false
+				}
+			}
+			m.skipLongerMatches = true
+			return false
+		case '?':
+			i++
+		case '[':
+			var matched bool
+			p, matched = matchClass(pattern, p+1, s[i], m.nocase)
+			if !matched {
+				return false
+			}
+			i++
+		default:
+			if pattern[p] == '\\' && p+1 < len(pattern) {
+				p++
+			}
+			if !equalByte(pattern[p], s[i], m.nocase) {
+				return false
+			}
+			i++
+		}
+		p++
+	}
+	// the string is consumed, only '*' can still match the empty rest
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern) && i == len(s)
+}
+
+// matchClass matches c against the character class starting at p, just after the '['. It
+// returns the position of the closing ']', or of the last byte when the class is not closed.
+func matchClass(pattern string, p int, c byte, nocase bool) (int, bool) {
+	not := p < len(pattern) && pattern[p] == '^'
+	if not {
+		p++
+	}
+	matched := false
+	for ; p < len(pattern); p++ {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if equalByte(pattern[p], c, nocase) {
+				matched = true
+			}
+		case pattern[p] == ']':
+			return p, matched != not
+		case p+2 < len(pattern) && pattern[p+1] == '-':
+			start, end := pattern[p], pattern[p+2]
+			if start > end {
+				start, end = end, start
+			}
+			lc := c
+			if nocase {
+				start, end, lc = lowerByte(start), lowerByte(end), lowerByte(c)
+			}
+			if lc >= start && lc <= end {
+				matched = true
+			}
+			p += 2
+		default:
+			if equalByte(pattern[p], c, nocase) {
+				matched = true
+			}
+		}
+	}
+	// an unterminated class behaves as if it was closed at the end of the pattern
+	return len(pattern) - 1, matched != not
+}
+
+func equalByte(a byte, b byte, nocase bool) bool {
+	if nocase {
+		return lowerByte(a) == lowerByte(b)
+	}
+	return a == b
+}
+
+func lowerByte(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
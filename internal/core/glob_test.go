@@ -0,0 +1,67 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		matches    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:42", true},
+		{"user:*", "users:42", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"[\\]]", "]", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+	}
+	for _, c := range cases {
+		assert.EqualValues(t, c.matches, stringMatch(c.pattern, c.s, false), "%s %s", c.pattern, c.s)
+	}
+	assert.True(t, stringMatch("HELLO*", "hello world", true))
+	assert.False(t, stringMatch("HELLO*", "hello world", false))
+}
+
+func TestStringMatch_Backtracking(t *testing.T) {
+	s := strings.Repeat("a", 100)
+	pattern := strings.Repeat("a*", 30) + "b"
+	done := make(chan bool)
+	go func() {
+		done <- stringMatch(pattern, s, false)
+	}()
+	select {
+	case matched := <-done:
+		assert.False(t, matched)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pathological pattern took too long")
+	}
+	assert.True(t, stringMatch(strings.Repeat("a*", 30), s, false))
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, validatePattern("user:*:name"))
+	assert.NoError(t, validatePattern(strings.Repeat("*", 5000)))
+	// escaped stars are plain characters
+	assert.NoError(t, validatePattern(strings.Repeat("a\\*", 2000)))
+	assert.ErrorIs(t, validatePattern(strings.Repeat("a*", globMaxNesting+1)), errInvalidPattern)
+	// the matcher itself refuses to recurse that deep
+	assert.False(t, stringMatch(strings.Repeat("a*", globMaxNesting+1)+"a", strings.Repeat("a", 2000), false))
+}
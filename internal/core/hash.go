@@ -0,0 +1,301 @@
+package core
+
+import (
+	"maps"
+	"math"
+	"math/rand"
+
+	"memkv/internal/config"
+)
+
+// HashField is a field of a hash along with its value
+type HashField struct {
+	Field, Value string
+}
+
+// Hash maps fields to values. Small hashes are stored in a listpack as field, value pairs
+// in insertion order, bigger ones in a Dict whose values are string objects.
+type Hash struct {
+	// exactly one of lp and dict is set
+	lp   *Listpack
+	dict *Dict
+	// expires maps the fields that have a time to live to their expiration time, unix time
+	// in milliseconds. It is nil until a field gets one.
+	expires map[string]int64
+	// nextExpire is at most the earliest time in expires, so accesses before it do not need
+	// to look for expired fields
+	nextExpire int64
+}
+
+// hrandfieldShuffleRatio is how much larger than count the hash must be for HRANDFIELD
+// to pick random fields one by one rather than shuffling all of them
+const hrandfieldShuffleRatio = 3
+
+func NewHash() *Hash {
+	return &Hash{lp: NewListpack()}
+}
+
+// Encoding returns ObjEncodingListpack or ObjEncodingHashtable
+func (h *Hash) Encoding() ObjEncoding {
+	if h.lp != nil {
+		return ObjEncodingListpack
+	}
+	return ObjEncodingHashtable
+}
+
+// Len returns the number of fields
+func (h *Hash) Len() int {
+	if h.lp != nil {
+		return h.lp.Len() / 2
+	}
+	return h.dict.Len()
+}
+
+// hlpFind returns the index of field in the listpack, its value follows it. It returns -1
+// when field is not in the hash.
+func hlpFind(lp *Listpack, field string) int {
+	index := 0
+	for p := lp.First(); p != -1; p = lp.Next(lp.Next(p)) {
+		if lp.Get(p) == field {
+			return index
+		}
+		index += 2
+	}
+	return -1
+}
+
+// Get returns the value of field
+func (h *Hash) Get(field string) (string, bool) {
+	if h.lp != nil {
+		index := hlpFind(h.lp, field)
+		if index == -1 {
+			return "", false
+		}
+		return h.lp.Get(h.lp.Seek(index + 1)), true
+	}
+	obj, ok := h.dict.Get(field)
+	if !ok {
+		return "", false
+	}
+	return obj.stringValue(), true
+}
+
+// Set sets field to value and reports whether the field is new. Like a plain HSET, the
+// new value does not inherit the time to live of the old one.
+func (h *Hash) Set(field string, value string) bool {
+	h.PersistField(field)
+	return h.SetKeepTTL(field, value)
+}
+
+// SetKeepTTL is Set that keeps the time to live of an existing field
+func (h *Hash) SetKeepTTL(field string, value string) bool {
+	if h.lp != nil {
+		index := hlpFind(h.lp, field)
+		switch {
+		case len(field) > config.HashMaxListpackValue || len(value) > config.HashMaxListpackValue:
+			h.convertToDict()
+		case index != -1:
+			h.lp.Replace(index+1, value)
+			return false
+		case h.Len()+1 > config.HashMaxListpackEntries:
+			h.convertToDict()
+		default:
+			h.lp.Append(field, value)
+			return true
+		}
+	}
+	_, exists := h.dict.Get(field)
+	h.dict.Set(field, newStringObj(value))
+	return !exists
+}
+
+// Delete removes field and reports whether it existed
+func (h *Hash) Delete(field string) bool {
+	h.PersistField(field)
+	if h.lp != nil {
+		index := hlpFind(h.lp, field)
+		if index == -1 {
+			return false
+		}
+		h.lp.Delete(index, 2)
+		return true
+	}
+	return h.dict.Delete(field)
+}
+
+// ForEach calls fn for every field until it returns false. fn must not modify the hash.
+func (h *Hash) ForEach(fn func(field string, value string) bool) {
+	if h.lp != nil {
+		for p := h.lp.First(); p != -1; p = h.lp.Next(h.lp.Next(p)) {
+			if !fn(h.lp.Get(p), h.lp.Get(h.lp.Next(p))) {
+				return
+			}
+		}
+		return
+	}
+	h.dict.ForEach(func(field string, obj *Obj) bool {
+		return fn(field, obj.stringValue())
+	})
+}
+
+// Fields returns all the fields with their values
+func (h *Hash) Fields() []HashField {
+	res := make([]HashField, 0, h.Len())
+	h.ForEach(func(field string, value string) bool {
+		res = append(res, HashField{Field: field, Value: value})
+		return true
+	})
+	return res
+}
+
+// RandomFields returns count random fields, possibly the same one several times with
+// repeat. Without repeat at most Len() fields are returned.
+func (h *Hash) RandomFields(count int64, repeat bool) []HashField {
+	length := int64(h.Len())
+	if length == 0 || count <= 0 {
+		return nil
+	}
+
+	if !repeat && count >= length {
+		fields := h.Fields()
+		rand.Shuffle(len(fields), func(i, j int) { fields[i], fields[j] = fields[j], fields[i] })
+		return fields
+	}
+
+	// count comes from the client, with repeat the reply grows as fields are picked
+	if h.lp == nil && (repeat || count <= length/hrandfieldShuffleRatio) {
+		// pick keys from the table without materializing all the fields
+		res := make([]HashField, 0, min(count, length))
+		var picked map[string]struct{}
+		if !repeat {
+			picked = make(map[string]struct{}, count)
+		}
+		for int64(len(res)) < count {
+			field, _ := h.dict.RandomKey()
+			if !repeat {
+				if _, ok := picked[field]; ok {
+					continue
+				}
+				picked[field] = struct{}{}
+			}
+			value, _ := h.Get(field)
+			res = append(res, HashField{Field: field, Value: value})
+		}
+		return res
+	}
+
+	fields := h.Fields()
+	if repeat {
+		res := make([]HashField, 0, min(count, length))
+		for i := int64(0); i < count; i++ {
+			res = append(res, fields[rand.Int63n(length)])
+		}
+		return res
+	}
+	rand.Shuffle(len(fields), func(i, j int) { fields[i], fields[j] = fields[j], fields[i] })
+	return fields[:count]
+}
+
+// Scan returns the fields of about count buckets starting at cursor and the cursor of the
+// next call, 0 once every field was returned. A listpack is small enough to be returned at
+// once.
+func (h *Hash) Scan(cursor uint64, count int) ([]HashField, uint64) {
+	if h.lp != nil {
+		return h.Fields(), 0
+	}
+	var res []HashField
+	maxIterations := count * 10
+	for {
+		cursor = h.dict.Scan(cursor, func(field string, obj *Obj) {
+			res = append(res, HashField{Field: field, Value: obj.stringValue()})
+		})
+		maxIterations--
+		if cursor == 0 || maxIterations == 0 || len(res) >= count {
+			break
+		}
+	}
+	return res, cursor
+}
+
+// Dup returns a copy of the hash
+func (h *Hash) Dup() *Hash {
+	dup := &Hash{nextExpire: h.nextExpire}
+	if h.expires != nil {
+		dup.expires = maps.Clone(h.expires)
+	}
+	if h.lp != nil {
+		dup.lp = h.lp.Dup()
+		return dup
+	}
+	dup.dict = CreateDict()
+	// string objects are immutable, they can be shared
+	h.dict.ForEach(func(field string, obj *Obj) bool {
+		dup.dict.Set(field, obj)
+		return true
+	})
+	return dup
+}
+
+// hasFieldExpires tells whether some fields have a time to live
+func (h *Hash) hasFieldExpires() bool {
+	return len(h.expires) > 0
+}
+
+// FieldExpire returns the expiration time of field, false if it has none
+func (h *Hash) FieldExpire(field string) (int64, bool) {
+	at, ok := h.expires[field]
+	return at, ok
+}
+
+// SetFieldExpire sets the expiration time of an existing field, unix time in milliseconds
+func (h *Hash) SetFieldExpire(field string, at int64) {
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+		h.nextExpire = at
+	}
+	h.expires[field] = at
+	h.nextExpire = min(h.nextExpire, at)
+}
+
+// PersistField removes the time to live of field and reports whether it had one
+func (h *Hash) PersistField(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	if len(h.expires) == 0 {
+		h.expires = nil
+	}
+	// nextExpire stays a valid lower bound
+	return true
+}
+
+// expireFields deletes the fields whose time to live has elapsed at now and returns how
+// many. It only goes through the fields once the earliest expiration time is reached.
+func (h *Hash) expireFields(now int64) int {
+	if len(h.expires) == 0 || now < h.nextExpire {
+		return 0
+	}
+	removed := 0
+	next := int64(math.MaxInt64)
+	for field, at := range h.expires {
+		if at <= now {
+			h.Delete(field)
+			removed++
+		} else {
+			next = min(next, at)
+		}
+	}
+	h.nextExpire = next
+	return removed
+}
+
+func (h *Hash) convertToDict() {
+	dict := CreateDict()
+	h.ForEach(func(field string, value string) bool {
+		dict.Set(field, newStringObj(value))
+		return true
+	})
+	h.lp = nil
+	h.dict = dict
+}
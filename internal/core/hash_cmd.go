@@ -0,0 +1,342 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+var (
+	errHashValueNotInteger = errors.New("ERR hash value is not an integer")
+	errHashValueNotFloat   = errors.New("ERR hash value is not a float")
+	errIncrOverflow        = errors.New("ERR increment or decrement would overflow")
+	errIncrNaNOrInfinity   = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// lookupOrCreateHash returns the hash stored at key, creating an empty one if needed
+func lookupOrCreateHash(key string) (*Hash, error) {
+	h, err := lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = NewHash()
+		setKey(key, newHashObj(h))
+	}
+	return h, nil
+}
+
+// HSET key field value [field value ...]
+func cmdHSET(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return respWrongNumberOfArgs("HSET")
+	}
+	h, err := lookupOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if h.Set(args[i], args[i+1]) {
+			added++
+		}
+	}
+	return Encode(added, false)
+}
+
+// HMSET key field value [field value ...]
+// Deprecated alias of HSET that replies OK.
+func cmdHMSET(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return respWrongNumberOfArgs("HMSET")
+	}
+	if res := cmdHSET(args); res[0] == '-' {
+		return res
+	}
+	return constants.RespOk
+}
+
+// HSETNX key field value
+func cmdHSETNX(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("HSETNX")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		h = NewHash()
+		setKey(args[0], newHashObj(h))
+	} else if _, ok := h.Get(args[1]); ok {
+		return constants.RespZero
+	}
+	h.Set(args[1], args[2])
+	return constants.RespOne
+}
+
+// HGET key field
+func cmdHGET(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("HGET")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespNil
+	}
+	value, ok := h.Get(args[1])
+	if !ok {
+		return constants.RespNil
+	}
+	return Encode(value, false)
+}
+
+// HMGET key field [field ...]
+func cmdHMGET(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("HMGET")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	// the missing fields are left nil and encoded as null bulk strings
+	res := make([]interface{}, len(args)-1)
+	if h == nil {
+		return Encode(res, false)
+	}
+	for i, field := range args[1:] {
+		if value, ok := h.Get(field); ok {
+			res[i] = value
+		}
+	}
+	return Encode(res, false)
+}
+
+// HDEL key field [field ...]
+func cmdHDEL(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("HDEL")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespZero
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		if h.Delete(field) {
+			deleted++
+		}
+	}
+	if h.Len() == 0 {
+		deleteKey(args[0])
+	}
+	return Encode(deleted, false)
+}
+
+// HEXISTS key field
+func cmdHEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("HEXISTS")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespZero
+	}
+	if _, ok := h.Get(args[1]); !ok {
+		return constants.RespZero
+	}
+	return constants.RespOne
+}
+
+// HLEN key
+func cmdHLEN(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("HLEN")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespZero
+	}
+	return Encode(h.Len(), false)
+}
+
+// HSTRLEN key field
+func cmdHSTRLEN(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("HSTRLEN")
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespZero
+	}
+	value, _ := h.Get(args[1])
+	return Encode(len(value), false)
+}
+
+// HKEYS key
+func cmdHKEYS(args []string) []byte {
+	return hgetallGeneric("HKEYS", args, true, false)
+}
+
+// HVALS key
+func cmdHVALS(args []string) []byte {
+	return hgetallGeneric("HVALS", args, false, true)
+}
+
+// HGETALL key
+func cmdHGETALL(args []string) []byte {
+	return hgetallGeneric("HGETALL", args, true, true)
+}
+
+func hgetallGeneric(cmdName string, args []string, withFields bool, withValues bool) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil {
+		return constants.RespEmptyArray
+	}
+	return encodeHashFields(h.Fields(), withFields, withValues)
+}
+
+// encodeHashFields encodes the fields, their values or both as a flat array
+func encodeHashFields(fields []HashField, withFields bool, withValues bool) []byte {
+	res := make([]string, 0, 2*len(fields))
+	for _, f := range fields {
+		if withFields {
+			res = append(res, f.Field)
+		}
+		if withValues {
+			res = append(res, f.Value)
+		}
+	}
+	return Encode(res, false)
+}
+
+// HINCRBY key field increment
+func cmdHINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("HINCRBY")
+	}
+	incr, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	h, err := lookupOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if value, ok := h.Get(args[1]); ok {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Encode(errHashValueNotInteger, false)
+		}
+	}
+	if (incr < 0 && current < math.MinInt64-incr) || (incr > 0 && current > math.MaxInt64-incr) {
+		return Encode(errIncrOverflow, false)
+	}
+	current += incr
+	h.SetKeepTTL(args[1], strconv.FormatInt(current, 10))
+	return Encode(current, false)
+}
+
+// HINCRBYFLOAT key field increment
+func cmdHINCRBYFLOAT(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("HINCRBYFLOAT")
+	}
+	incr, ok := parseDouble(args[2])
+	if !ok {
+		return Encode(errNotFloat, false)
+	}
+	if math.IsInf(incr, 0) {
+		return Encode(errIncrNaNOrInfinity, false)
+	}
+	h, err := lookupOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if value, ok := h.Get(args[1]); ok {
+		if current, ok = parseDouble(value); !ok {
+			return Encode(errHashValueNotFloat, false)
+		}
+	}
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return Encode(errIncrNaNOrInfinity, false)
+	}
+	value := formatDouble(current)
+	h.SetKeepTTL(args[1], value)
+	return Encode(value, false)
+}
+
+// HRANDFIELD key [count [WITHVALUES]]
+// A positive count returns distinct fields, a negative one allows repetitions and always
+// returns -count fields.
+func cmdHRANDFIELD(args []string) []byte {
+	if len(args) < 1 || len(args) > 3 {
+		return respWrongNumberOfArgs("HRANDFIELD")
+	}
+	if len(args) == 1 {
+		h, err := lookupHash(args[0])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if h == nil {
+			return constants.RespNil
+		}
+		return Encode(h.RandomFields(1, true)[0].Field, false)
+	}
+
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	withValues := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHVALUES" {
+			return Encode(errSyntax, false)
+		}
+		withValues = true
+	}
+	// the reply holds up to 2*|count| items, refuse counts that cannot be represented
+	if count < -maxRandomRepeatCount || count > math.MaxInt64/2 {
+		return Encode(errOutOfRange, false)
+	}
+
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if h == nil || count == 0 {
+		return constants.RespEmptyArray
+	}
+	if count < 0 {
+		return encodeHashFields(h.RandomFields(-count, true), true, withValues)
+	}
+	return encodeHashFields(h.RandomFields(count, false), true, withValues)
+}
@@ -0,0 +1,370 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+// Replies given for each field by the commands managing the time to live of hash fields
+const (
+	hfieldNotFound   = -2 // The field, or the hash, does not exist
+	hfieldNoExpire   = -1 // The field has no time to live
+	hfieldNotSet     = 0  // The NX, XX, GT or LT condition is not met
+	hfieldExpireSet  = 1  // The time to live was set, or removed by HPERSIST
+	hfieldExpiredNow = 2  // The expiration time is in the past, the field was deleted
+)
+
+var (
+	errFieldsMissing     = errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	errNumFields         = errors.New("ERR Parameter `numFields` should be greater than 0")
+	errNumFieldsMismatch = errors.New("ERR The `numfields` parameter must match the number of arguments")
+)
+
+// indexOfFields returns the position of the FIELDS argument at or after from, -1 if absent
+func indexOfFields(args []string, from int) int {
+	for i := from; i < len(args); i++ {
+		if strings.EqualFold(args[i], "FIELDS") {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFields parses "FIELDS numfields field [field ...]", with a value after every field
+// when withValues is set. It returns the fields, or the fields and the values interleaved.
+func parseFields(args []string, withValues bool) ([]string, error) {
+	if len(args) < 2 || !strings.EqualFold(args[0], "FIELDS") {
+		return nil, errFieldsMissing
+	}
+	numFields, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || numFields <= 0 {
+		return nil, errNumFields
+	}
+	perField := int64(1)
+	if withValues {
+		perField = 2
+	}
+	if numFields > math.MaxInt64/perField || numFields*perField != int64(len(args)-2) {
+		return nil, errNumFieldsMismatch
+	}
+	return args[2:], nil
+}
+
+// afterFieldExpiresChange deletes the hash at key if it lost all its fields, or makes
+// sure the active expire cycle goes through it if some fields have a time to live
+func afterFieldExpiresChange(key string, h *Hash) {
+	if h.Len() == 0 {
+		deleteKey(key)
+	} else if h.hasFieldExpires() {
+		trackHashFieldExpires(key)
+	}
+}
+
+// HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func cmdHEXPIRE(args []string) []byte {
+	return hexpireGeneric(args, "HEXPIRE", 1000, false)
+}
+
+// HPEXPIRE key milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func cmdHPEXPIRE(args []string) []byte {
+	return hexpireGeneric(args, "HPEXPIRE", 1, false)
+}
+
+// HEXPIREAT key unix-time-seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func cmdHEXPIREAT(args []string) []byte {
+	return hexpireGeneric(args, "HEXPIREAT", 1000, true)
+}
+
+// HPEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func cmdHPEXPIREAT(args []string) []byte {
+	return hexpireGeneric(args, "HPEXPIREAT", 1, true)
+}
+
+// hexpireGeneric implements the HEXPIRE family like expireGeneric does for keys, replying
+// with one of the hfield codes for each field
+func hexpireGeneric(args []string, cmdName string, unit int64, absolute bool) []byte {
+	if len(args) < 5 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	key := args[0]
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	fieldsIdx := indexOfFields(args, 2)
+	if fieldsIdx == -1 {
+		return Encode(errFieldsMissing, false)
+	}
+	flags, err := parseExpireFlags(args[2:fieldsIdx])
+	if err != nil {
+		return Encode(err, false)
+	}
+	fields, err := parseFields(args[fieldsIdx:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(cmdName))
+	if n < 0 || n > math.MaxInt64/unit {
+		return Encode(errInvalid, false)
+	}
+	now := currentTimeMs()
+	at := n * unit
+	if !absolute {
+		if at > math.MaxInt64-now {
+			return Encode(errInvalid, false)
+		}
+		at += now
+	}
+
+	h, err := lookupHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		res[i] = hfieldNotFound
+		if h == nil {
+			continue
+		}
+		if _, ok := h.Get(field); !ok {
+			continue
+		}
+		cur, hasExpire := h.FieldExpire(field)
+		switch {
+		case flags&expireInNX != 0 && hasExpire,
+			flags&expireInXX != 0 && !hasExpire,
+			// a field without expiry has an infinite time to live
+			flags&expireInGT != 0 && (!hasExpire || at <= cur),
+			flags&expireInLT != 0 && hasExpire && at >= cur:
+			res[i] = hfieldNotSet
+		case at <= now:
+			h.Delete(field)
+			res[i] = hfieldExpiredNow
+		default:
+			h.SetFieldExpire(field, at)
+			res[i] = hfieldExpireSet
+		}
+	}
+	if h != nil {
+		afterFieldExpiresChange(key, h)
+	}
+	return Encode(res, false)
+}
+
+// HTTL key FIELDS numfields field [field ...]
+func cmdHTTL(args []string) []byte {
+	return httlGeneric(args, "HTTL", false, false)
+}
+
+// HPTTL key FIELDS numfields field [field ...]
+func cmdHPTTL(args []string) []byte {
+	return httlGeneric(args, "HPTTL", true, false)
+}
+
+// HEXPIRETIME key FIELDS numfields field [field ...]
+func cmdHEXPIRETIME(args []string) []byte {
+	return httlGeneric(args, "HEXPIRETIME", false, true)
+}
+
+// HPEXPIRETIME key FIELDS numfields field [field ...]
+func cmdHPEXPIRETIME(args []string) []byte {
+	return httlGeneric(args, "HPEXPIRETIME", true, true)
+}
+
+// httlGeneric replies with the remaining time to live of each field, or its expiration
+// time when absolute is true, in milliseconds or seconds
+func httlGeneric(args []string, cmdName string, inMs bool, absolute bool) []byte {
+	if len(args) < 4 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	fields, err := parseFields(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		res[i] = hfieldNotFound
+		if h == nil {
+			continue
+		}
+		if _, ok := h.Get(field); !ok {
+			continue
+		}
+		at, hasExpire := h.FieldExpire(field)
+		if !hasExpire {
+			res[i] = hfieldNoExpire
+			continue
+		}
+		if !absolute {
+			at = max(at-currentTimeMs(), 0)
+		}
+		if !inMs {
+			at = (at + 500) / 1000
+		}
+		res[i] = at
+	}
+	return Encode(res, false)
+}
+
+// HPERSIST key FIELDS numfields field [field ...]
+func cmdHPERSIST(args []string) []byte {
+	if len(args) < 4 {
+		return respWrongNumberOfArgs("HPERSIST")
+	}
+	fields, err := parseFields(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	h, err := lookupHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		res[i] = hfieldNotFound
+		if h == nil {
+			continue
+		}
+		if _, ok := h.Get(field); !ok {
+			continue
+		}
+		if h.PersistField(field) {
+			res[i] = hfieldExpireSet
+		} else {
+			res[i] = hfieldNoExpire
+		}
+	}
+	return Encode(res, false)
+}
+
+// HGETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+// FIELDS numfields field [field ...]
+func cmdHGETEX(args []string) []byte {
+	if len(args) < 4 {
+		return respWrongNumberOfArgs("HGETEX")
+	}
+	key := args[0]
+	fieldsIdx := indexOfFields(args, 1)
+	if fieldsIdx == -1 {
+		return Encode(errFieldsMissing, false)
+	}
+	flags, expireAt, err := parseSetOptions(args[1:fieldsIdx], setInExpire|getExPersist, "HGETEX")
+	if err != nil {
+		return Encode(err, false)
+	}
+	fields, err := parseFields(args[fieldsIdx:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	h, err := lookupHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	// the missing fields are left nil and encoded as null bulk strings
+	res := make([]interface{}, len(fields))
+	if h == nil {
+		return Encode(res, false)
+	}
+	now := currentTimeMs()
+	for i, field := range fields {
+		value, ok := h.Get(field)
+		if !ok {
+			continue
+		}
+		res[i] = value
+		switch {
+		case flags&setInExpire != 0 && expireAt <= now:
+			h.Delete(field)
+		case flags&setInExpire != 0:
+			h.SetFieldExpire(field, expireAt)
+		case flags&getExPersist != 0:
+			h.PersistField(field)
+		}
+	}
+	afterFieldExpiresChange(key, h)
+	return Encode(res, false)
+}
+
+// HSETEX key [FNX | FXX] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+// FIELDS numfields field value [field value ...]
+// FNX only sets the fields if none of them exists, FXX if all of them exist. It replies 1
+// when the fields were set, 0 otherwise.
+func cmdHSETEX(args []string) []byte {
+	if len(args) < 5 {
+		return respWrongNumberOfArgs("HSETEX")
+	}
+	key := args[0]
+	fieldsIdx := indexOfFields(args, 1)
+	if fieldsIdx == -1 {
+		return Encode(errFieldsMissing, false)
+	}
+	var onlyNew, onlyExisting bool
+	var opts []string
+	for _, arg := range args[1:fieldsIdx] {
+		switch {
+		case strings.EqualFold(arg, "FNX") && !onlyExisting:
+			onlyNew = true
+		case strings.EqualFold(arg, "FXX") && !onlyNew:
+			onlyExisting = true
+		default:
+			opts = append(opts, arg)
+		}
+	}
+	flags, expireAt, err := parseSetOptions(opts, setInExpire|setInKeepTTL, "HSETEX")
+	if err != nil {
+		return Encode(err, false)
+	}
+	pairs, err := parseFields(args[fieldsIdx:], true)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	h, err := lookupHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if onlyNew || onlyExisting {
+		for i := 0; i < len(pairs); i += 2 {
+			exists := false
+			if h != nil {
+				_, exists = h.Get(pairs[i])
+			}
+			if exists == onlyNew {
+				return constants.RespZero
+			}
+		}
+	}
+	if h == nil {
+		h = NewHash()
+		setKey(key, newHashObj(h))
+	}
+	now := currentTimeMs()
+	for i := 0; i < len(pairs); i += 2 {
+		field, value := pairs[i], pairs[i+1]
+		switch {
+		case flags&setInKeepTTL != 0:
+			h.SetKeepTTL(field, value)
+		case flags&setInExpire != 0 && expireAt <= now:
+			// set and expired right away
+			h.Delete(field)
+		default:
+			h.Set(field, value)
+			if flags&setInExpire != 0 {
+				h.SetFieldExpire(field, expireAt)
+			}
+		}
+	}
+	afterFieldExpiresChange(key, h)
+	return constants.RespOne
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createNumberedHash returns a hash mapping the fields "f0"... to "v0"..., stored in a
+// hash table unless n is small
+func createNumberedHash(n int) *Hash {
+	h := NewHash()
+	for i := 0; i < n; i++ {
+		h.Set(fmt.Sprintf("f%d", i), fmt.Sprintf("v%d", i))
+	}
+	return h
+}
+
+func sortedFields(fields []HashField) []HashField {
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+func TestHash_SetGetDelete(t *testing.T) {
+	for _, h := range []*Hash{createNumberedHash(0), createNumberedHash(200)} {
+		encoding := h.Encoding()
+		length := h.Len()
+
+		assert.True(t, h.Set("a", "1"))
+		assert.False(t, h.Set("a", "2"))
+		assert.True(t, h.Set("b", "-9223372036854775808"))
+		assert.True(t, h.Set("c", "007"))
+		assert.EqualValues(t, length+3, h.Len())
+		assert.EqualValues(t, encoding, h.Encoding())
+
+		value, ok := h.Get("a")
+		assert.True(t, ok)
+		assert.EqualValues(t, "2", value)
+		value, _ = h.Get("b")
+		assert.EqualValues(t, "-9223372036854775808", value)
+		value, _ = h.Get("c")
+		assert.EqualValues(t, "007", value)
+		_, ok = h.Get("missing")
+		assert.False(t, ok)
+
+		assert.True(t, h.Delete("b"))
+		assert.False(t, h.Delete("b"))
+		_, ok = h.Get("b")
+		assert.False(t, ok)
+		value, _ = h.Get("c")
+		assert.EqualValues(t, "007", value)
+		assert.EqualValues(t, length+2, h.Len())
+	}
+}
+
+func TestHash_ListpackConversion(t *testing.T) {
+	h := createNumberedHash(128)
+	assert.EqualValues(t, ObjEncodingListpack, h.Encoding())
+	// updating a field does not add one
+	h.Set("f0", "new")
+	assert.EqualValues(t, ObjEncodingListpack, h.Encoding())
+	h.Set("one more", "x")
+	assert.EqualValues(t, ObjEncodingHashtable, h.Encoding())
+	assert.EqualValues(t, 129, h.Len())
+	value, _ := h.Get("f0")
+	assert.EqualValues(t, "new", value)
+	value, _ = h.Get("f127")
+	assert.EqualValues(t, "v127", value)
+
+	h = createNumberedHash(2)
+	h.Set("f1", strings.Repeat("x", 65))
+	assert.EqualValues(t, ObjEncodingHashtable, h.Encoding())
+	assert.EqualValues(t, 2, h.Len())
+
+	h = createNumberedHash(2)
+	h.Set(strings.Repeat("x", 65), "v")
+	assert.EqualValues(t, ObjEncodingHashtable, h.Encoding())
+	assert.EqualValues(t, 3, h.Len())
+}
+
+func TestHash_ListpackKeepsInsertionOrder(t *testing.T) {
+	h := NewHash()
+	h.Set("z", "1")
+	h.Set("a", "2")
+	h.Set("m", "3")
+	h.Delete("a")
+	h.Set("a", "4")
+	assert.EqualValues(t, []HashField{{"z", "1"}, {"m", "3"}, {"a", "4"}}, h.Fields())
+}
+
+func TestHash_RandomFields(t *testing.T) {
+	for _, h := range []*Hash{createNumberedHash(10), createNumberedHash(200)} {
+		length := h.Len()
+		assertDistinct := func(fields []HashField) {
+			seen := make(map[string]bool)
+			for _, f := range fields {
+				assert.False(t, seen[f.Field])
+				seen[f.Field] = true
+				value, _ := h.Get(f.Field)
+				assert.EqualValues(t, value, f.Value)
+			}
+		}
+
+		assert.Nil(t, h.RandomFields(0, false))
+		assert.EqualValues(t, 3, len(h.RandomFields(3, false)))
+		assertDistinct(h.RandomFields(3, false))
+		assertDistinct(h.RandomFields(int64(length-1), false))
+		all := h.RandomFields(int64(length+5), false)
+		assert.EqualValues(t, length, len(all))
+		assertDistinct(all)
+		assert.EqualValues(t, length, len(h.RandomFields(math.MaxInt64/2, false)))
+		assert.EqualValues(t, 3*length, len(h.RandomFields(int64(3*length), true)))
+	}
+}
+
+func TestHash_Scan(t *testing.T) {
+	for _, h := range []*Hash{createNumberedHash(10), createNumberedHash(500)} {
+		var fields []HashField
+		cursor := uint64(0)
+		for {
+			var batch []HashField
+			batch, cursor = h.Scan(cursor, 10)
+			fields = append(fields, batch...)
+			if cursor == 0 {
+				break
+			}
+		}
+		assert.EqualValues(t, sortedFields(h.Fields()), sortedFields(fields))
+	}
+}
+
+func TestHash_Dup(t *testing.T) {
+	for _, h := range []*Hash{createNumberedHash(5), createNumberedHash(200)} {
+		dup := h.Dup()
+		assert.EqualValues(t, h.Encoding(), dup.Encoding())
+		assert.EqualValues(t, sortedFields(h.Fields()), sortedFields(dup.Fields()))
+
+		dup.Delete("f0")
+		dup.Set("f1", "changed")
+		_, ok := h.Get("f0")
+		assert.True(t, ok)
+		value, _ := h.Get("f1")
+		assert.EqualValues(t, "v1", value)
+	}
+}
+
+func TestHash_FieldExpires(t *testing.T) {
+	for _, h := range []*Hash{createNumberedHash(5), createNumberedHash(200)} {
+		now := currentTimeMs()
+		h.SetFieldExpire("f0", now-1)
+		h.SetFieldExpire("f1", now+1000)
+		h.SetFieldExpire("f2", now-2)
+		h.SetFieldExpire("f3", now+1000)
+		// HSET forgets the time to live, HINCRBY keeps it
+		h.Set("f3", "new")
+		h.SetKeepTTL("f1", "new")
+		_, ok := h.FieldExpire("f3")
+		assert.False(t, ok)
+		at, ok := h.FieldExpire("f1")
+		assert.True(t, ok)
+		assert.EqualValues(t, now+1000, at)
+
+		dup := h.Dup()
+		length := h.Len()
+		assert.EqualValues(t, 2, h.expireFields(now))
+		assert.EqualValues(t, length-2, h.Len())
+		_, ok = h.Get("f0")
+		assert.False(t, ok)
+		assert.EqualValues(t, now+1000, h.nextExpire)
+		// nothing left to expire before nextExpire
+		assert.EqualValues(t, 0, h.expireFields(now+999))
+
+		// the copy has its own times to live
+		assert.EqualValues(t, 2, dup.expireFields(now))
+		assert.True(t, dup.PersistField("f1"))
+		assert.False(t, dup.PersistField("f1"))
+		assert.False(t, dup.hasFieldExpires())
+		assert.True(t, h.hasFieldExpires())
+
+		h.Delete("f1")
+		assert.False(t, h.hasFieldExpires())
+	}
+}
+
+func TestHash_LastFieldExpiryDeletesKey(t *testing.T) {
+	h := createNumberedHash(2)
+	setKey("hexp", newHashObj(h))
+	h.SetFieldExpire("f0", currentTimeMs()-1)
+	trackHashFieldExpires("hexp")
+	assert.NotNil(t, lookupKey("hexp"))
+	assert.EqualValues(t, 1, h.Len())
+
+	h.SetFieldExpire("f1", currentTimeMs()-1)
+	assert.Nil(t, lookupKey("hexp"))
+	_, ok := keyspace.Get("hexp")
+	assert.False(t, ok)
+	assert.NotContains(t, hashesWithFieldExpires, "hexp")
+}
+
+func TestHRANDFIELD_HugeCount(t *testing.T) {
+	cmdHSET([]string{"hrand", "a", "1", "b", "2"})
+	defer deleteKey("hrand")
+	outOfRange := "-" + errOutOfRange.Error() + "\r\n"
+	assert.EqualValues(t, outOfRange, string(cmdHRANDFIELD([]string{"hrand", "-100000000000"})))
+	assert.EqualValues(t, outOfRange, string(cmdHRANDFIELD([]string{"hrand", "4611686018427387904"})))
+	assert.EqualValues(t, "*4\r\n", string(cmdHRANDFIELD([]string{"hrand", "4611686018427387903", "WITHVALUES"}))[:4])
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// Intset is a sorted array of distinct integers packed with the width of the largest one,
+// 2, 4 or 8 bytes. Adding a value that does not fit upgrades every element to the larger
+// width, the width never shrinks back. Lookups are binary searches and updates move the
+// tail of the array, which stays cheap for the small sets using this encoding.
+type Intset struct {
+	width    int // bytes per element
+	contents []byte
+}
+
+func NewIntset() *Intset {
+	return &Intset{width: 2}
+}
+
+// intsetWidthFor returns the smallest width able to hold v
+func intsetWidthFor(v int64) int {
+	switch {
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4
+	}
+	return 8
+}
+
+// Len returns the number of elements
+func (is *Intset) Len() int {
+	return len(is.contents) / is.width
+}
+
+// Bytes returns the size of the packed elements
+func (is *Intset) Bytes() int {
+	return len(is.contents)
+}
+
+// Get returns the element at index, in ascending order
+func (is *Intset) Get(index int) int64 {
+	b := is.contents[index*is.width:]
+	switch is.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (is *Intset) set(index int, v int64) {
+	b := is.contents[index*is.width:]
+	switch is.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(v))
+	}
+}
+
+// search returns the index of v, or the index where it would be inserted and false
+func (is *Intset) search(v int64) (int, bool) {
+	n := is.Len()
+	i := sort.Search(n, func(i int) bool { return is.Get(i) >= v })
+	return i, i < n && is.Get(i) == v
+}
+
+// Contains tells whether v is in the set
+func (is *Intset) Contains(v int64) bool {
+	if intsetWidthFor(v) > is.width {
+		return false
+	}
+	_, ok := is.search(v)
+	return ok
+}
+
+// Add inserts v and reports whether it was not in the set yet
+func (is *Intset) Add(v int64) bool {
+	if intsetWidthFor(v) > is.width {
+		is.upgradeAndAdd(v)
+		return true
+	}
+	index, ok := is.search(v)
+	if ok {
+		return false
+	}
+	is.contents = append(is.contents, make([]byte, is.width)...)
+	copy(is.contents[(index+1)*is.width:], is.contents[index*is.width:])
+	is.set(index, v)
+	return true
+}
+
+// upgradeAndAdd widens every element to fit v, which is then either smaller or larger
+// than all of them and goes first or last
+func (is *Intset) upgradeAndAdd(v int64) {
+	oldWidth, n := is.width, is.Len()
+	old := is.contents
+	is.width = intsetWidthFor(v)
+	is.contents = make([]byte, (n+1)*is.width)
+	offset := 0
+	if v < 0 {
+		offset = 1
+		is.set(0, v)
+	} else {
+		is.set(n, v)
+	}
+	oldSet := &Intset{width: oldWidth, contents: old}
+	for i := 0; i < n; i++ {
+		is.set(i+offset, oldSet.Get(i))
+	}
+}
+
+// Remove deletes v and reports whether it was in the set
+func (is *Intset) Remove(v int64) bool {
+	if intsetWidthFor(v) > is.width {
+		return false
+	}
+	index, ok := is.search(v)
+	if !ok {
+		return false
+	}
+	copy(is.contents[index*is.width:], is.contents[(index+1)*is.width:])
+	is.contents = is.contents[:len(is.contents)-is.width]
+	return true
+}
+
+// Dup returns a copy of the set
+func (is *Intset) Dup() *Intset {
+	return &Intset{width: is.width, contents: append([]byte(nil), is.contents...)}
+}
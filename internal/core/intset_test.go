@@ -0,0 +1,68 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intsetValues(is *Intset) []int64 {
+	res := make([]int64, is.Len())
+	for i := range res {
+		res[i] = is.Get(i)
+	}
+	return res
+}
+
+func TestIntset_AddRemove(t *testing.T) {
+	is := NewIntset()
+	expected := make(map[int64]bool)
+	for i := 0; i < 500; i++ {
+		v := int64(rand.Intn(2000) - 1000)
+		assert.EqualValues(t, !expected[v], is.Add(v))
+		expected[v] = true
+	}
+	assert.EqualValues(t, 2, is.width)
+	assert.EqualValues(t, len(expected), is.Len())
+	values := intsetValues(is)
+	assert.True(t, sort.SliceIsSorted(values, func(i, j int) bool { return values[i] < values[j] }))
+
+	for v := range expected {
+		assert.True(t, is.Contains(v))
+		assert.True(t, is.Remove(v))
+		assert.False(t, is.Remove(v))
+		assert.False(t, is.Contains(v))
+	}
+	assert.EqualValues(t, 0, is.Len())
+}
+
+func TestIntset_Upgrade(t *testing.T) {
+	is := NewIntset()
+	is.Add(5)
+	is.Add(-3)
+	assert.EqualValues(t, 4, is.Bytes())
+
+	// a value needing a larger width goes first or last
+	is.Add(math.MinInt32)
+	assert.EqualValues(t, 4, is.width)
+	assert.EqualValues(t, []int64{math.MinInt32, -3, 5}, intsetValues(is))
+	is.Add(math.MaxInt64)
+	assert.EqualValues(t, 8, is.width)
+	assert.EqualValues(t, []int64{math.MinInt32, -3, 5, math.MaxInt64}, intsetValues(is))
+
+	assert.False(t, NewIntset().Contains(math.MaxInt64))
+	assert.False(t, NewIntset().Remove(math.MinInt64))
+
+	// the width does not shrink back
+	is.Remove(math.MaxInt64)
+	is.Remove(math.MinInt32)
+	assert.EqualValues(t, 8, is.width)
+	assert.EqualValues(t, []int64{-3, 5}, intsetValues(is))
+
+	dup := is.Dup()
+	dup.Add(7)
+	assert.EqualValues(t, []int64{-3, 5}, intsetValues(is))
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+var (
+	errNoSuchKey         = errors.New(constants.ResponseNoSuchKey)
+	errSameObject        = errors.New("ERR source and destination objects are the same")
+	errDBIndexOutOfRange = errors.New("ERR DB index is out of range")
+)
+
+// TYPE key
+func cmdTYPE(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs(CommandType)
+	}
+	obj := lookupKey(args[0])
+	if obj == nil {
+		return Encode("none", true)
+	}
+	return Encode(obj.Type.String(), true)
+}
+
+// DEL key [key ...]
+func cmdDEL(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs(CommandDel)
+	}
+	deleted := 0
+	for _, key := range args {
+		// an expired key is not counted as deleted
+		if !expireIfNeeded(key) && deleteKey(key) {
+			deleted++
+		}
+	}
+	return Encode(deleted, false)
+}
+
+// UNLINK key [key ...]
+// Removing a key is O(1) whatever its value: the memory is reclaimed by the garbage
+// collector, which runs concurrently with the event loop. DEL never frees a large value on
+// the event loop either, so UNLINK behaves exactly like it.
+func cmdUNLINK(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("UNLINK")
+	}
+	return cmdDEL(args)
+}
+
+// EXISTS key [key ...]
+// A key mentioned several times is counted several times, as Redis does.
+func cmdEXISTS(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs(CommandExists)
+	}
+	count := 0
+	for _, key := range args {
+		if lookupKey(key) != nil {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// RENAME key newkey
+func cmdRENAME(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs(CommandRename)
+	}
+	if err := renameKey(args[0], args[1]); err != nil {
+		return Encode(err, false)
+	}
+	return constants.RespOk
+}
+
+// RENAMENX key newkey
+// The key is only renamed when newkey does not exist.
+func cmdRENAMENX(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("RENAMENX")
+	}
+	key, newKey := args[0], args[1]
+	if lookupKey(key) == nil {
+		return Encode(errNoSuchKey, false)
+	}
+	if key == newKey || lookupKey(newKey) != nil {
+		return constants.RespZero
+	}
+	renameKey(key, newKey)
+	return constants.RespOne
+}
+
+// renameKey moves the value of key, along with its time to live, to newKey
+func renameKey(key string, newKey string) error {
+	obj := lookupKey(key)
+	if obj == nil {
+		return errNoSuchKey
+	}
+	if key == newKey {
+		return nil
+	}
+	expireAt, hasExpire := getExpire(key)
+	deleteKey(key)
+	setKey(newKey, obj)
+	if hasExpire {
+		setExpire(newKey, expireAt)
+	}
+	return nil
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+// There is a single database, 0.
+func cmdCOPY(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("COPY")
+	}
+	src, dst := args[0], args[1]
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "REPLACE"):
+			replace = true
+		case strings.EqualFold(args[i], "DB") && i+1 < len(args):
+			db, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			if db != 0 {
+				return Encode(errDBIndexOutOfRange, false)
+			}
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if src == dst {
+		return Encode(errSameObject, false)
+	}
+
+	obj := lookupKey(src)
+	if obj == nil {
+		return constants.RespZero
+	}
+	if lookupKey(dst) != nil && !replace {
+		return constants.RespZero
+	}
+	expireAt, hasExpire := getExpire(src)
+	setKey(dst, obj.dup())
+	if hasExpire {
+		setExpire(dst, expireAt)
+	}
+	return constants.RespOne
+}
+
+// TOUCH key [key ...]
+// It returns how many of the keys exist and marks them as accessed.
+func cmdTOUCH(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("TOUCH")
+	}
+	count := 0
+	for _, key := range args {
+		if lookupKey(key) != nil {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// randomKeyMaxTries bounds how many expired keys RANDOMKEY reclaims before giving up,
+// a keyspace where most keys expired together would otherwise be swept in one command
+const randomKeyMaxTries = 100
+
+// RANDOMKEY
+// Like Redis, once the tries are exhausted the last sampled key is returned even if it expired.
+func cmdRANDOMKEY(args []string) []byte {
+	if len(args) != 0 {
+		return respWrongNumberOfArgs("RANDOMKEY")
+	}
+	for tries := 1; ; tries++ {
+		key, ok := keyspace.RandomKey()
+		if !ok {
+			return constants.RespNil
+		}
+		if tries == randomKeyMaxTries || !expireIfNeeded(key) {
+			return Encode(key, false)
+		}
+	}
+}
+
+// DBSIZE
+// Keys whose time to live elapsed but were not reclaimed yet are counted.
+func cmdDBSIZE(args []string) []byte {
+	if len(args) != 0 {
+		return respWrongNumberOfArgs("DBSIZE")
+	}
+	return Encode(keyspace.Len(), false)
+}
+
+// KEYS pattern
+// It walks the whole keyspace at once, SCAN is the way to go through a large one.
+func cmdKEYS(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs(CommandKeys)
+	}
+	pattern := args[0]
+	if err := validatePattern(pattern); err != nil {
+		return Encode(err, false)
+	}
+	matchAll := pattern == "*"
+	var matched []string
+	keyspace.ForEach(func(key string, _ *Obj) bool {
+		if matchAll || stringMatch(pattern, key, false) {
+			matched = append(matched, key)
+		}
+		return true
+	})
+	res := matched[:0]
+	for _, key := range matched {
+		if !expireIfNeeded(key) {
+			res = append(res, key)
+		}
+	}
+	return Encode(res, false)
+}
+
+// OBJECT ENCODING key
+func cmdOBJECT(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("OBJECT")
+	}
+	switch sub := strings.ToUpper(args[0]); {
+	case sub == "ENCODING" && len(args) == 2:
+		obj := lookupKey(args[1])
+		if obj == nil {
+			return constants.RespNil
+		}
+		return Encode(obj.encoding().String(), false)
+	case sub == "ENCODING":
+		return Encode(fmt.Errorf("ERR wrong number of arguments for 'object|%s' command", strings.ToLower(args[0])), false)
+	default:
+		return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[0]), false)
+	}
+}
@@ -0,0 +1,590 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+var (
+	errIndexOutOfRange = errors.New("ERR index out of range")
+	errLPosRankZero    = errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+	errLPosCount       = errors.New("ERR COUNT can't be negative")
+	errLPosMaxLen      = errors.New("ERR MAXLEN can't be negative")
+)
+
+// LPUSH key element [element ...]
+func cmdLPUSH(args []string) []byte {
+	return pushGeneric("LPUSH", args, true, false)
+}
+
+// RPUSH key element [element ...]
+func cmdRPUSH(args []string) []byte {
+	return pushGeneric("RPUSH", args, false, false)
+}
+
+// LPUSHX key element [element ...]
+func cmdLPUSHX(args []string) []byte {
+	return pushGeneric("LPUSHX", args, true, true)
+}
+
+// RPUSHX key element [element ...]
+func cmdRPUSHX(args []string) []byte {
+	return pushGeneric("RPUSHX", args, false, true)
+}
+
+// pushGeneric pushes the elements one after the other at the head, or the tail, of the
+// list, creating it unless onlyIfExists is set. It replies with the length of the list.
+func pushGeneric(cmdName string, args []string, head bool, onlyIfExists bool) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	key := args[0]
+	ql, err := lookupList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		if onlyIfExists {
+			return constants.RespZero
+		}
+		ql = NewQuicklist()
+		setKey(key, newListObj(ql))
+	} else {
+		signalKeyAsReady(key)
+	}
+	for _, element := range args[1:] {
+		if head {
+			ql.PushHead(element)
+		} else {
+			ql.PushTail(element)
+		}
+	}
+	return Encode(ql.Len(), false)
+}
+
+// LPOP key [count]
+func cmdLPOP(args []string) []byte {
+	return popGeneric("LPOP", args, true)
+}
+
+// RPOP key [count]
+func cmdRPOP(args []string) []byte {
+	return popGeneric("RPOP", args, false)
+}
+
+// popGeneric pops one element, or an array of count elements when count is given
+func popGeneric(cmdName string, args []string, head bool) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	count := int64(-1)
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil || count < 0 {
+			return Encode(errValueOutOfRange, false)
+		}
+	}
+
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		if count == -1 {
+			return constants.RespNil
+		}
+		return constants.RespNilArray
+	}
+	if count == -1 {
+		return Encode(listPop(args[0], ql, 1, head)[0], false)
+	}
+	return Encode(listPop(args[0], ql, count, head), false)
+}
+
+// listPop removes and returns up to count elements from the head or the tail of the list
+// stored at key, in the order they were popped. The key is deleted once the list is empty.
+func listPop(key string, ql *Quicklist, count int64, head bool) []string {
+	n := int(min(count, int64(ql.Len())))
+	var res []string
+	if head {
+		res = ql.Range(0, n-1)
+		ql.DeleteRange(0, n)
+	} else {
+		res = ql.Range(ql.Len()-n, ql.Len()-1)
+		ql.DeleteRange(ql.Len()-n, n)
+		slices.Reverse(res)
+	}
+	if ql.Len() == 0 {
+		deleteKey(key)
+	}
+	return res
+}
+
+// LMPOP numkeys key [key ...] LEFT | RIGHT [COUNT count]
+func cmdLMPOP(args []string) []byte {
+	if len(args) < 3 {
+		return respWrongNumberOfArgs("LMPOP")
+	}
+	keys, fromTail, count, err := parseMPopArgs(args, lmpopSides)
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, key := range keys {
+		ql, err := lookupList(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if ql != nil {
+			return encodeLMPop(key, listPop(key, ql, count, !fromTail))
+		}
+	}
+	return constants.RespNilArray
+}
+
+var lmpopSides = [2]string{"LEFT", "RIGHT"}
+
+// encodeLMPop builds the reply of LMPOP and BLMPOP: the key and its popped elements
+func encodeLMPop(key string, elements []string) []byte {
+	return Encode([]interface{}{key, elements}, false)
+}
+
+// BLPOP key [key ...] timeout
+func cmdBLPOP(args []string, c io.ReadWriter) []byte {
+	return bpopGeneric("BLPOP", args, true, c)
+}
+
+// BRPOP key [key ...] timeout
+func cmdBRPOP(args []string, c io.ReadWriter) []byte {
+	return bpopGeneric("BRPOP", args, false, c)
+}
+
+func bpopGeneric(cmdName string, args []string, head bool, c io.ReadWriter) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	deadline, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys := args[:len(args)-1]
+	if err := checkListKeys(keys); err != nil {
+		return Encode(err, false)
+	}
+	return serveOrBlock(c, keys, deadline, constants.RespNilArray, func(key string) []byte {
+		ql, _ := lookupList(key)
+		if ql == nil {
+			return nil
+		}
+		return Encode([]string{key, listPop(key, ql, 1, head)[0]}, false)
+	})
+}
+
+// BLMPOP timeout numkeys key [key ...] LEFT | RIGHT [COUNT count]
+func cmdBLMPOP(args []string, c io.ReadWriter) []byte {
+	if len(args) < 4 {
+		return respWrongNumberOfArgs("BLMPOP")
+	}
+	deadline, err := parseTimeout(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys, fromTail, count, err := parseMPopArgs(args[1:], lmpopSides)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if err := checkListKeys(keys); err != nil {
+		return Encode(err, false)
+	}
+	return serveOrBlock(c, keys, deadline, constants.RespNilArray, func(key string) []byte {
+		ql, _ := lookupList(key)
+		if ql == nil {
+			return nil
+		}
+		return encodeLMPop(key, listPop(key, ql, count, !fromTail))
+	})
+}
+
+// checkListKeys fails with ErrWrongType when one of the keys holds something else than a
+// list, blocking on such a key could never be served
+func checkListKeys(keys []string) error {
+	for _, key := range keys {
+		if _, err := lookupList(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LRANGE key start stop
+func cmdLRANGE(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("LRANGE")
+	}
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespEmptyArray
+	}
+	length := int64(ql.Len())
+	start, end = normalizeRankRange(start, end, length)
+	if start > end || start >= length {
+		return constants.RespEmptyArray
+	}
+	end = min(end, length-1)
+	return Encode(ql.Range(int(start), int(end)), false)
+}
+
+// LINDEX key index
+func cmdLINDEX(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("LINDEX")
+	}
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespNil
+	}
+	value, ok := ql.Index(int(index))
+	if !ok {
+		return constants.RespNil
+	}
+	return Encode(value, false)
+}
+
+// LSET key index element
+func cmdLSET(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("LSET")
+	}
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return Encode(errNoSuchKey, false)
+	}
+	if !ql.Replace(int(index), args[2]) {
+		return Encode(errIndexOutOfRange, false)
+	}
+	return constants.RespOk
+}
+
+// LINSERT key BEFORE | AFTER pivot element
+// It replies with the new length, or -1 when the pivot is not in the list.
+func cmdLINSERT(args []string) []byte {
+	if len(args) != 4 {
+		return respWrongNumberOfArgs("LINSERT")
+	}
+	var after bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		after = false
+	case "AFTER":
+		after = true
+	default:
+		return Encode(errSyntax, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespZero
+	}
+
+	pivot := -1
+	ql.ForEach(false, func(index int, value string) bool {
+		if value == args[2] {
+			pivot = index
+			return false
+		}
+		return true
+	})
+	if pivot == -1 {
+		return Encode(-1, false)
+	}
+	if after {
+		pivot++
+	}
+	ql.Insert(pivot, args[3])
+	return Encode(ql.Len(), false)
+}
+
+// LLEN key
+func cmdLLEN(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("LLEN")
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespZero
+	}
+	return Encode(ql.Len(), false)
+}
+
+// LREM key count element
+// A positive count removes the first count occurrences, a negative one the last -count
+// occurrences and 0 all of them.
+func cmdLREM(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("LREM")
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if count == math.MinInt64 {
+		// -count does not fit in an int64
+		return Encode(errOutOfRange, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespZero
+	}
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := ql.Remove(args[2], int(min(limit, int64(ql.Len()))), count < 0)
+	if ql.Len() == 0 {
+		deleteKey(args[0])
+	}
+	return Encode(removed, false)
+}
+
+// LTRIM key start stop
+func cmdLTRIM(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("LTRIM")
+	}
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		return constants.RespOk
+	}
+	length := int64(ql.Len())
+	start, end = normalizeRankRange(start, end, length)
+	if start > end || start >= length {
+		deleteKey(args[0])
+		return constants.RespOk
+	}
+	end = min(end, length-1)
+	ql.DeleteRange(int(end+1), int(length-end-1))
+	ql.DeleteRange(0, int(start))
+	return constants.RespOk
+}
+
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+// RANK skips the first rank-1 matches, a negative rank searches from the tail. COUNT
+// returns the positions of that many matches, 0 for all, MAXLEN compares only that many
+// elements.
+func cmdLPOS(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("LPOS")
+	}
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return Encode(errLPosRankZero, false)
+			}
+			if n == math.MinInt64 {
+				// -n does not fit in an int64
+				return Encode(errOutOfRange, false)
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return Encode(errLPosCount, false)
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return Encode(errLPosMaxLen, false)
+			}
+			maxLen = n
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	ql, err := lookupList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if ql == nil {
+		if count != -1 {
+			return constants.RespEmptyArray
+		}
+		return constants.RespNil
+	}
+
+	reverse := rank < 0
+	skip := rank - 1
+	if reverse {
+		skip = -rank - 1
+	}
+	var matches []int
+	compared := int64(0)
+	ql.ForEach(reverse, func(index int, value string) bool {
+		if maxLen != 0 && compared == maxLen {
+			return false
+		}
+		compared++
+		if value != args[1] {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		matches = append(matches, index)
+		return count == 0 || int64(len(matches)) < max(count, 1)
+	})
+
+	if count == -1 {
+		if len(matches) == 0 {
+			return constants.RespNil
+		}
+		return Encode(matches[0], false)
+	}
+	res := make([]interface{}, len(matches))
+	for i, m := range matches {
+		res[i] = m
+	}
+	return Encode(res, false)
+}
+
+// LMOVE source destination LEFT | RIGHT LEFT | RIGHT
+func cmdLMOVE(args []string) []byte {
+	if len(args) != 4 {
+		return respWrongNumberOfArgs("LMOVE")
+	}
+	fromHead, ok1 := parseListSide(args[2])
+	toHead, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return Encode(errSyntax, false)
+	}
+	return listMove(args[0], args[1], fromHead, toHead)
+}
+
+// RPOPLPUSH source destination
+func cmdRPOPLPUSH(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("RPOPLPUSH")
+	}
+	return listMove(args[0], args[1], false, true)
+}
+
+// BLMOVE source destination LEFT | RIGHT LEFT | RIGHT timeout
+func cmdBLMOVE(args []string, c io.ReadWriter) []byte {
+	if len(args) != 5 {
+		return respWrongNumberOfArgs("BLMOVE")
+	}
+	fromHead, ok1 := parseListSide(args[2])
+	toHead, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return Encode(errSyntax, false)
+	}
+	return blmoveGeneric(args[0], args[1], fromHead, toHead, args[4], c)
+}
+
+// BRPOPLPUSH source destination timeout
+func cmdBRPOPLPUSH(args []string, c io.ReadWriter) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("BRPOPLPUSH")
+	}
+	return blmoveGeneric(args[0], args[1], false, true, args[2], c)
+}
+
+func blmoveGeneric(src string, dst string, fromHead bool, toHead bool, timeout string, c io.ReadWriter) []byte {
+	deadline, err := parseTimeout(timeout)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if err := checkListKeys([]string{src}); err != nil {
+		return Encode(err, false)
+	}
+	return serveOrBlock(c, []string{src}, deadline, constants.RespNil, func(key string) []byte {
+		if ql, _ := lookupList(src); ql == nil {
+			return nil
+		}
+		// a destination holding another type fails the command, it does not keep it blocked
+		return listMove(src, dst, fromHead, toHead)
+	})
+}
+
+// parseListSide parses the LEFT or RIGHT argument of LMOVE, it returns true for LEFT
+func parseListSide(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// listMove pops an element from src and pushes it to dst, it replies with the element or
+// nil when src does not exist
+func listMove(src string, dst string, fromHead bool, toHead bool) []byte {
+	srcList, err := lookupList(src)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if srcList == nil {
+		return constants.RespNil
+	}
+	// check the destination before popping, the element must not get lost
+	if _, err := lookupList(dst); err != nil {
+		return Encode(err, false)
+	}
+	element := listPop(src, srcList, 1, fromHead)[0]
+	pushGeneric("LMOVE", []string{dst, element}, toHead, false)
+	return Encode(element, false)
+}
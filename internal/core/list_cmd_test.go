@@ -0,0 +1,105 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listContents returns the elements of the list at key
+func listContents(key string) []string {
+	ql, _ := lookupList(key)
+	if ql == nil {
+		return nil
+	}
+	return ql.Range(0, ql.Len()-1)
+}
+
+func TestLPOS(t *testing.T) {
+	cmdRPUSH([]string{"lpos", "a", "b", "c", "1", "2", "3", "c", "c"})
+	defer deleteKey("lpos")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"lpos", "c"}, ":2\r\n"},
+		{[]string{"lpos", "missing"}, "$-1\r\n"},
+		{[]string{"nokey", "c"}, "$-1\r\n"},
+		{[]string{"lpos", "c", "RANK", "2"}, ":6\r\n"},
+		{[]string{"lpos", "c", "RANK", "-1"}, ":7\r\n"},
+		{[]string{"lpos", "c", "RANK", "4"}, "$-1\r\n"},
+		{[]string{"lpos", "c", "COUNT", "2"}, "*2\r\n:2\r\n:6\r\n"},
+		{[]string{"lpos", "c", "COUNT", "0"}, "*3\r\n:2\r\n:6\r\n:7\r\n"},
+		{[]string{"lpos", "c", "RANK", "-1", "COUNT", "2"}, "*2\r\n:7\r\n:6\r\n"},
+		{[]string{"lpos", "c", "RANK", "2", "COUNT", "0"}, "*2\r\n:6\r\n:7\r\n"},
+		{[]string{"lpos", "missing", "COUNT", "1"}, "*0\r\n"},
+		{[]string{"nokey", "c", "COUNT", "1"}, "*0\r\n"},
+		{[]string{"lpos", "c", "COUNT", "0", "MAXLEN", "7"}, "*2\r\n:2\r\n:6\r\n"},
+		{[]string{"lpos", "c", "RANK", "-1", "COUNT", "0", "MAXLEN", "2"}, "*2\r\n:7\r\n:6\r\n"},
+		{[]string{"lpos", "c", "MAXLEN", "2"}, "$-1\r\n"},
+		{[]string{"lpos", "c", "RANK", "0"}, "-" + errLPosRankZero.Error() + "\r\n"},
+		{[]string{"lpos", "c", "RANK", "-9223372036854775808"}, "-" + errOutOfRange.Error() + "\r\n"},
+		{[]string{"lpos", "c", "COUNT", "-1"}, "-" + errLPosCount.Error() + "\r\n"},
+		{[]string{"lpos", "c", "MAXLEN", "-1"}, "-" + errLPosMaxLen.Error() + "\r\n"},
+		{[]string{"lpos", "c", "RANK"}, "-" + errSyntax.Error() + "\r\n"},
+	}
+	for _, tc := range tests {
+		assert.EqualValues(t, tc.want, string(cmdLPOS(tc.args)), "%v", tc.args)
+	}
+}
+
+func TestLREM(t *testing.T) {
+	tests := []struct {
+		count string
+		want  string
+		left  []string
+	}{
+		{"2", ":2\r\n", []string{"b", "x", "c", "x"}},
+		{"-2", ":2\r\n", []string{"x", "b", "x", "c"}},
+		{"0", ":4\r\n", []string{"b", "c"}},
+		{"100", ":4\r\n", []string{"b", "c"}},
+		{"-9223372036854775807", ":4\r\n", []string{"b", "c"}},
+		{"-9223372036854775808", "-" + errOutOfRange.Error() + "\r\n", []string{"x", "b", "x", "x", "c", "x"}},
+	}
+	for _, tc := range tests {
+		deleteKey("lrem")
+		cmdRPUSH([]string{"lrem", "x", "b", "x", "x", "c", "x"})
+		assert.EqualValues(t, tc.want, string(cmdLREM([]string{"lrem", tc.count, "x"})), tc.count)
+		assert.EqualValues(t, tc.left, listContents("lrem"), tc.count)
+	}
+
+	// removing every element deletes the key
+	assert.EqualValues(t, ":4\r\n", string(cmdLREM([]string{"lrem", "0", "x"})))
+	cmdLREM([]string{"lrem", "0", "b"})
+	assert.EqualValues(t, ":1\r\n", string(cmdLREM([]string{"lrem", "-1", "c"})))
+	assert.Nil(t, lookupKey("lrem"))
+	assert.EqualValues(t, ":0\r\n", string(cmdLREM([]string{"lrem", "0", "c"})))
+}
+
+func TestLTRIM(t *testing.T) {
+	tests := []struct {
+		start, stop string
+		left        []string
+	}{
+		{"1", "2", []string{"b", "c"}},
+		{"0", "-1", []string{"a", "b", "c", "d", "e"}},
+		{"-2", "-1", []string{"d", "e"}},
+		{"-100", "1", []string{"a", "b"}},
+		{"3", "100", []string{"d", "e"}},
+		{"3", "1", nil},
+		{"5", "10", nil},
+	}
+	for _, tc := range tests {
+		deleteKey("ltrim")
+		cmdRPUSH([]string{"ltrim", "a", "b", "c", "d", "e"})
+		assert.EqualValues(t, "+OK\r\n", string(cmdLTRIM([]string{"ltrim", tc.start, tc.stop})))
+		assert.EqualValues(t, tc.left, listContents("ltrim"), "%s %s", tc.start, tc.stop)
+		if tc.left == nil {
+			assert.Nil(t, lookupKey("ltrim"))
+		}
+	}
+	deleteKey("ltrim")
+	assert.EqualValues(t, "+OK\r\n", string(cmdLTRIM([]string{"ltrim", "0", "1"})))
+	assert.EqualValues(t, "-"+errNotInteger.Error()+"\r\n", string(cmdLTRIM([]string{"ltrim", "a", "1"})))
+}
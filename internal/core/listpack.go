@@ -0,0 +1,257 @@
+package core
+
+import (
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// Listpack is a list of strings serialized in a single byte slice. It trades O(n) access
+// for the absence of per-element allocations and pointers, which makes it the encoding
+// of choice for small collections.
+//
+// Every entry is laid out as
+//
+//	<header> <payload> <backlen>
+//
+// The header is a uvarint whose lowest bit tells the kind of entry: for a string it holds
+// the length of the payload that follows, for an integer it holds the zigzag-encoded value
+// itself and there is no payload. Only strings that are the canonical representation of
+// an int64 are stored as integers, so Get always returns what was stored.
+//
+// backlen is the size of header and payload, written so it can be read from its last byte
+// backwards: 7 bits per byte, the lowest bits last, the high bit set on every byte but the
+// first one. It is what lets the list be walked from the tail.
+type Listpack struct {
+	data   []byte
+	length int
+}
+
+const (
+	lpEncodingString = 0
+	lpEncodingInt    = 1
+)
+
+func NewListpack() *Listpack {
+	return &Listpack{}
+}
+
+// Len returns the number of entries
+func (lp *Listpack) Len() int {
+	return lp.length
+}
+
+// Bytes returns the size of the serialized entries
+func (lp *Listpack) Bytes() int {
+	return len(lp.data)
+}
+
+// First returns the position of the first entry, -1 if the listpack is empty
+func (lp *Listpack) First() int {
+	if lp.length == 0 {
+		return -1
+	}
+	return 0
+}
+
+// Last returns the position of the last entry, -1 if the listpack is empty
+func (lp *Listpack) Last() int {
+	if lp.length == 0 {
+		return -1
+	}
+	return lp.Prev(len(lp.data))
+}
+
+// Next returns the position of the entry after the one at p, -1 if p is the last one
+func (lp *Listpack) Next(p int) int {
+	p += lp.entrySize(p)
+	if p >= len(lp.data) {
+		return -1
+	}
+	return p
+}
+
+// Prev returns the position of the entry before the one at p, -1 if p is the first one.
+// p can be Bytes() to get the last entry.
+func (lp *Listpack) Prev(p int) int {
+	if p <= 0 {
+		return -1
+	}
+	size, end := 0, p-1
+	for shift := 0; ; shift += 7 {
+		b := lp.data[end]
+		size |= int(b&127) << shift
+		end--
+		if b&128 == 0 {
+			break
+		}
+	}
+	return end + 1 - size
+}
+
+// Seek returns the position of the entry at index, negative indexes count from the tail.
+// It returns -1 when the index is out of range.
+func (lp *Listpack) Seek(index int) int {
+	if index < 0 {
+		index += lp.length
+	}
+	if index < 0 || index >= lp.length {
+		return -1
+	}
+	if index < lp.length/2 {
+		p := 0
+		for ; index > 0; index-- {
+			p = lp.Next(p)
+		}
+		return p
+	}
+	p := len(lp.data)
+	for i := lp.length; i > index; i-- {
+		p = lp.Prev(p)
+	}
+	return p
+}
+
+// Get returns the entry at p
+func (lp *Listpack) Get(p int) string {
+	header, n := binary.Uvarint(lp.data[p:])
+	if header&1 == lpEncodingInt {
+		return strconv.FormatInt(zigzagDecode(header>>1), 10)
+	}
+	start := p + n
+	return string(lp.data[start : start+int(header>>1)])
+}
+
+// ForEach calls fn with the index and the value of every entry from the head until fn
+// returns false
+func (lp *Listpack) ForEach(fn func(index int, value string) bool) {
+	for i, p := 0, lp.First(); p != -1; i, p = i+1, lp.Next(p) {
+		if !fn(i, lp.Get(p)) {
+			return
+		}
+	}
+}
+
+// Append adds values at the tail
+func (lp *Listpack) Append(values ...string) {
+	lp.Insert(lp.length, values...)
+}
+
+// Insert adds values before the entry at index, index can be Len() to append
+func (lp *Listpack) Insert(index int, values ...string) {
+	p := len(lp.data)
+	if index < lp.length {
+		p = lp.Seek(index)
+	}
+	var buf []byte
+	for _, v := range values {
+		buf = appendListpackEntry(buf, v)
+	}
+	lp.data = slices.Insert(lp.data, p, buf...)
+	lp.length += len(values)
+}
+
+// Delete removes count entries starting at index
+func (lp *Listpack) Delete(index int, count int) {
+	start := lp.Seek(index)
+	if start == -1 || count <= 0 {
+		return
+	}
+	end := start
+	deleted := 0
+	for ; deleted < count && end < len(lp.data); deleted++ {
+		end += lp.entrySize(end)
+	}
+	lp.data = slices.Delete(lp.data, start, end)
+	lp.length -= deleted
+}
+
+// DeleteAt removes the entry at p and returns the position of the entry that followed it,
+// -1 if it was the last one
+func (lp *Listpack) DeleteAt(p int) int {
+	lp.data = slices.Delete(lp.data, p, p+lp.entrySize(p))
+	lp.length--
+	if p >= len(lp.data) {
+		return -1
+	}
+	return p
+}
+
+// Replace overwrites the entry at index with value
+func (lp *Listpack) Replace(index int, value string) {
+	p := lp.Seek(index)
+	if p == -1 {
+		return
+	}
+	lp.data = slices.Replace(lp.data, p, p+lp.entrySize(p), appendListpackEntry(nil, value)...)
+}
+
+// Dup returns a copy of the listpack
+func (lp *Listpack) Dup() *Listpack {
+	return &Listpack{data: slices.Clone(lp.data), length: lp.length}
+}
+
+// entrySize returns the size of the whole entry at p, backlen included
+func (lp *Listpack) entrySize(p int) int {
+	header, n := binary.Uvarint(lp.data[p:])
+	size := n
+	if header&1 == lpEncodingString {
+		size += int(header >> 1)
+	}
+	return size + backlenSize(size)
+}
+
+func appendListpackEntry(buf []byte, value string) []byte {
+	start := len(buf)
+	// the kind bit takes one bit of the header, the few integers whose zigzag encoding
+	// needs all 64 bits are stored as strings
+	if n, ok := canonicalInt64(value); ok && zigzagEncode(n) < 1<<63 {
+		buf = binary.AppendUvarint(buf, zigzagEncode(n)<<1|lpEncodingInt)
+	} else {
+		buf = binary.AppendUvarint(buf, uint64(len(value))<<1|lpEncodingString)
+		buf = append(buf, value...)
+	}
+	return appendBacklen(buf, len(buf)-start)
+}
+
+func appendBacklen(buf []byte, size int) []byte {
+	n := backlenSize(size)
+	for i := n - 1; i >= 0; i-- {
+		b := byte(size>>(7*i)) & 127
+		if i != n-1 {
+			b |= 128
+		}
+		buf = append(buf, b)
+	}
+	return buf
+}
+
+func backlenSize(size int) int {
+	n := 1
+	for size >= 128 {
+		size >>= 7
+		n++
+	}
+	return n
+}
+
+func zigzagEncode(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func zigzagDecode(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// canonicalInt64 parses s as an integer only when formatting the integer gives s back,
+// so "007" or "+1" are kept as strings
+func canonicalInt64(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != s {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listpackValues(lp *Listpack) []string {
+	var res []string
+	lp.ForEach(func(_ int, value string) bool {
+		res = append(res, value)
+		return true
+	})
+	return res
+}
+
+func TestListpack_AppendAndWalk(t *testing.T) {
+	lp := NewListpack()
+	assert.EqualValues(t, -1, lp.First())
+	assert.EqualValues(t, -1, lp.Last())
+
+	// a mix of integers, strings that look like integers, and a value needing a multi byte backlen
+	long := strings.Repeat("x", 300)
+	values := []string{"a", "12", "-9223372036854775808", "007", "+1", "", long, "1.5"}
+	lp.Append(values...)
+	assert.EqualValues(t, len(values), lp.Len())
+	assert.EqualValues(t, values, listpackValues(lp))
+
+	var backwards []string
+	for p := lp.Last(); p != -1; p = lp.Prev(p) {
+		backwards = append(backwards, lp.Get(p))
+	}
+	for i := range values {
+		assert.EqualValues(t, values[len(values)-1-i], backwards[i])
+	}
+
+	assert.EqualValues(t, long, lp.Get(lp.Seek(6)))
+	assert.EqualValues(t, "1.5", lp.Get(lp.Seek(-1)))
+	assert.EqualValues(t, "a", lp.Get(lp.Seek(-8)))
+	assert.EqualValues(t, -1, lp.Seek(8))
+	assert.EqualValues(t, -1, lp.Seek(-9))
+}
+
+func TestListpack_InsertDeleteReplace(t *testing.T) {
+	lp := NewListpack()
+	lp.Append("b", "d")
+	lp.Insert(0, "a")
+	lp.Insert(2, "c")
+	lp.Insert(4, "e", "f")
+	assert.EqualValues(t, []string{"a", "b", "c", "d", "e", "f"}, listpackValues(lp))
+
+	lp.Replace(1, strings.Repeat("b", 200))
+	lp.Replace(2, "3")
+	assert.EqualValues(t, strings.Repeat("b", 200), lp.Get(lp.Seek(1)))
+	assert.EqualValues(t, "3", lp.Get(lp.Seek(2)))
+	assert.EqualValues(t, "f", lp.Get(lp.Last()))
+
+	lp.Delete(1, 2)
+	assert.EqualValues(t, []string{"a", "d", "e", "f"}, listpackValues(lp))
+	lp.Delete(2, 10)
+	assert.EqualValues(t, []string{"a", "d"}, listpackValues(lp))
+	lp.Delete(0, 2)
+	assert.EqualValues(t, 0, lp.Len())
+	assert.EqualValues(t, 0, lp.Bytes())
+}
+
+func TestListpack_IntegersAreCompact(t *testing.T) {
+	lp := NewListpack()
+	for i := 0; i < 100; i++ {
+		lp.Append(fmt.Sprint(i * 1000))
+	}
+	// every entry fits in a 3 bytes header and a 1 byte backlen
+	assert.LessOrEqual(t, lp.Bytes(), 100*4)
+	assert.EqualValues(t, "99000", lp.Get(lp.Last()))
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ObjType uint8
+
+const (
+	ObjTypeString ObjType = iota
+	ObjTypeList
+	ObjTypeSet
+	ObjTypeZSet
+	ObjTypeHash
+)
+
+var objTypeNames = map[ObjType]string{
+	ObjTypeString: "string",
+	ObjTypeList:   "list",
+	ObjTypeSet:    "set",
+	ObjTypeZSet:   "zset",
+	ObjTypeHash:   "hash",
+}
+
+func (t ObjType) String() string {
+	return objTypeNames[t]
+}
+
+// objTypeByName returns the type called name, as TYPE reports it
+func objTypeByName(name string) (ObjType, bool) {
+	for t, n := range objTypeNames {
+		if strings.EqualFold(n, name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ObjEncoding is the internal representation of a value, one type can have several encodings
+type ObjEncoding uint8
+
+const (
+	ObjEncodingRaw ObjEncoding = iota
+	ObjEncodingInt
+	ObjEncodingSkiplist
+	ObjEncodingListpack
+	ObjEncodingQuicklist
+	ObjEncodingHashtable
+	ObjEncodingIntset
+)
+
+var objEncodingNames = map[ObjEncoding]string{
+	ObjEncodingRaw:       "raw",
+	ObjEncodingInt:       "int",
+	ObjEncodingSkiplist:  "skiplist",
+	ObjEncodingListpack:  "listpack",
+	ObjEncodingQuicklist: "quicklist",
+	ObjEncodingHashtable: "hashtable",
+	ObjEncodingIntset:    "intset",
+}
+
+func (e ObjEncoding) String() string {
+	return objEncodingNames[e]
+}
+
+// encodedValue is implemented by the values that pick their encoding themselves and
+// convert it as they grow
+type encodedValue interface {
+	Encoding() ObjEncoding
+}
+
+// Obj is a value stored in the keyspace
+type Obj struct {
+	Type     ObjType
+	Encoding ObjEncoding
+	Value    interface{}
+	// lastAccessedAt is the unix time in milliseconds of the last command that touched the value
+	lastAccessedAt int64
+}
+
+func newObj(objType ObjType, encoding ObjEncoding, value interface{}) *Obj {
+	return &Obj{
+		Type:           objType,
+		Encoding:       encoding,
+		Value:          value,
+		lastAccessedAt: time.Now().UnixMilli(),
+	}
+}
+
+// newStringObj creates a string value, stored as an integer when it is the canonical
+// representation of one so "123" does not need a string allocation.
+func newStringObj(s string) *Obj {
+	if n, ok := canonicalInt64(s); ok {
+		return newObj(ObjTypeString, ObjEncodingInt, n)
+	}
+	return newObj(ObjTypeString, ObjEncodingRaw, s)
+}
+
+// stringValue returns the content of a string object
+func (o *Obj) stringValue() string {
+	if o.Encoding == ObjEncodingInt {
+		return strconv.FormatInt(o.Value.(int64), 10)
+	}
+	return o.Value.(string)
+}
+
+// encoding returns the current encoding of the value, which can differ from the one it was
+// created with for the values that convert themselves
+func (o *Obj) encoding() ObjEncoding {
+	if v, ok := o.Value.(encodedValue); ok {
+		return v.Encoding()
+	}
+	return o.Encoding
+}
+
+// dup returns a deep copy of the object, modifying the copy leaves the original untouched
+func (o *Obj) dup() *Obj {
+	value := o.Value
+	switch v := o.Value.(type) {
+	case *ZSet:
+		value = v.Dup()
+	case *Quicklist:
+		value = v.Dup()
+	case *Hash:
+		value = v.Dup()
+	case *Set:
+		value = v.Dup()
+	}
+	// strings are immutable, they can be shared
+	return newObj(o.Type, o.Encoding, value)
+}
+
+func newListObj(ql *Quicklist) *Obj {
+	return newObj(ObjTypeList, ObjEncodingQuicklist, ql)
+}
+
+func newHashObj(h *Hash) *Obj {
+	return newObj(ObjTypeHash, h.Encoding(), h)
+}
+
+func newSetObj(s *Set) *Obj {
+	return newObj(ObjTypeSet, s.Encoding(), s)
+}
+
+func newZSetObj(zs *ZSet) *Obj {
+	return newObj(ObjTypeZSet, zs.Encoding(), zs)
+}
@@ -5,6 +5,7 @@ package processor
 import (
 	"log"
 	"syscall"
+	"time"
 )
 
 // EpollProcessor is a structure that implements the Multiplexer interface
@@ -46,9 +47,26 @@ func (fd *EpollProcessor) Monitor(event Event) error {
 	return syscall.EpollCtl(fd.fd, syscall.EPOLL_CTL_ADD, event.Fd, &epollEvent)
 }
 
+// Modify implements Multiplexer.
+func (fd *EpollProcessor) Modify(event Event) error {
+	epollEvent := event.toNative()
+	return syscall.EpollCtl(fd.fd, syscall.EPOLL_CTL_MOD, event.Fd, &epollEvent)
+}
+
+// Unmonitor implements Multiplexer.
+func (fd *EpollProcessor) Unmonitor(connFd int) error {
+	// kernels before 2.6.9 require a non-nil event even for EPOLL_CTL_DEL
+	return syscall.EpollCtl(fd.fd, syscall.EPOLL_CTL_DEL, connFd, &syscall.EpollEvent{})
+}
+
 // Check implements Multiplexer.
-func (fd *EpollProcessor) Check() ([]Event, error) {
-	n, err := syscall.EpollWait(fd.fd, fd.epollEvents, -1)
+func (fd *EpollProcessor) Check(timeout time.Duration) ([]Event, error) {
+	msec := -1
+	if timeout >= 0 {
+		// round up, waking up before the deadline would only make the loop spin
+		msec = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	n, err := syscall.EpollWait(fd.fd, fd.epollEvents, msec)
 	if err != nil {
 		return nil, err
 	}
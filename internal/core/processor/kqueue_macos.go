@@ -2,7 +2,10 @@
 
 package processor
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+)
 
 type KqueueProcessor struct {
 	fd            int
@@ -27,13 +30,54 @@ func CreateIoMultiplexer() (*KqueueProcessor, error) {
 
 // Monitor implements Multiplexer.
 func (k *KqueueProcessor) Monitor(event Event) error {
+	if event.Op == OperationReadWrite {
+		return k.Modify(event)
+	}
 	_, err := syscall.Kevent(k.fd, []syscall.Kevent_t{event.toNative(syscall.EV_ADD)}, nil, nil)
 	return err
 }
 
+// Modify implements Multiplexer.
+// Kqueue tracks read and write as two separate filters, so each one is added or deleted on its own.
+func (k *KqueueProcessor) Modify(event Event) error {
+	read := event.Op == OperationRead || event.Op == OperationReadWrite
+	write := event.Op == OperationWrite || event.Op == OperationReadWrite
+	if err := k.setFilter(event.Fd, OperationRead, read); err != nil {
+		return err
+	}
+	return k.setFilter(event.Fd, OperationWrite, write)
+}
+
+// Unmonitor implements Multiplexer.
+func (k *KqueueProcessor) Unmonitor(fd int) error {
+	if err := k.setFilter(fd, OperationRead, false); err != nil {
+		return err
+	}
+	return k.setFilter(fd, OperationWrite, false)
+}
+
+func (k *KqueueProcessor) setFilter(fd int, op Operation, enabled bool) error {
+	var flags uint16 = syscall.EV_ADD
+	if !enabled {
+		flags = syscall.EV_DELETE
+	}
+	change := Event{Fd: fd, Op: op}.toNative(flags)
+	_, err := syscall.Kevent(k.fd, []syscall.Kevent_t{change}, nil, nil)
+	if err == syscall.ENOENT && !enabled {
+		// the filter was not registered, nothing to delete
+		return nil
+	}
+	return err
+}
+
 // Check implements Multiplexer.
-func (k *KqueueProcessor) Check() ([]Event, error) {
-	n, err := syscall.Kevent(k.fd, nil, k.kqEvents, nil)
+func (k *KqueueProcessor) Check(timeout time.Duration) ([]Event, error) {
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(timeout.Nanoseconds())
+		ts = &t
+	}
+	n, err := syscall.Kevent(k.fd, nil, k.kqEvents, ts)
 	if err != nil {
 		return nil, err
 	}
@@ -1,5 +1,7 @@
 package processor
 
+import "time"
+
 const (
 	MaxConnection = 1024
 )
@@ -7,8 +9,11 @@ const (
 type Operation uint32
 
 const (
-	OperationRead  Operation = 0
-	OperationWrite Operation = 1
+	OperationRead      Operation = 0
+	OperationWrite     Operation = 1
+	OperationReadWrite Operation = 2
+	// OperationError is reported when the fd hung up or is in error state, it should be closed
+	OperationError Operation = 3
 )
 
 type Event struct {
@@ -18,6 +23,11 @@ type Event struct {
 
 type Multiplexer interface {
 	Monitor(event Event) error
-	Check() ([]Event, error)
+	// Modify replaces the operations a monitored fd is waiting for
+	Modify(event Event) error
+	// Unmonitor stops watching fd, it must be called before the fd is closed
+	Unmonitor(fd int) error
+	// Check waits at most timeout for ready fds, a negative timeout waits until one is ready
+	Check(timeout time.Duration) ([]Event, error)
 	Close() error
 }
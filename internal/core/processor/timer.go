@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Timer is a time event of the event loop. One-shot timers fire once, repeating timers are
+// rescheduled period after each run.
+type Timer struct {
+	id       int64
+	when     time.Time
+	period   time.Duration
+	callback func(now time.Time)
+	index    int // position in the heap, -1 once the timer left it
+	removed  bool
+}
+
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].id < h[j].id
+	}
+	return h[i].when.Before(h[j].when)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// TimerQueue keeps the timers of the event loop ordered by deadline, so the loop knows how
+// long it may wait for I/O before some timer is due.
+type TimerQueue struct {
+	timers timerHeap
+	byID   map[int64]*Timer
+	nextID int64
+}
+
+func NewTimerQueue() *TimerQueue {
+	return &TimerQueue{
+		byID: make(map[int64]*Timer),
+	}
+}
+
+// AddOnce registers callback to run once, delay from now. It returns the id of the timer.
+func (q *TimerQueue) AddOnce(delay time.Duration, callback func(now time.Time)) int64 {
+	return q.add(delay, 0, callback)
+}
+
+// AddRepeating registers callback to run every period, starting period from now.
+func (q *TimerQueue) AddRepeating(period time.Duration, callback func(now time.Time)) int64 {
+	return q.add(period, period, callback)
+}
+
+func (q *TimerQueue) add(delay time.Duration, period time.Duration, callback func(now time.Time)) int64 {
+	q.nextID++
+	t := &Timer{
+		id:       q.nextID,
+		when:     time.Now().Add(delay),
+		period:   period,
+		callback: callback,
+	}
+	heap.Push(&q.timers, t)
+	q.byID[t.id] = t
+	return t.id
+}
+
+// Remove cancels a timer and reports whether it was still registered.
+// It is safe to call from a timer callback, including for the running timer.
+func (q *TimerQueue) Remove(id int64) bool {
+	t, ok := q.byID[id]
+	if !ok {
+		return false
+	}
+	delete(q.byID, id)
+	t.removed = true
+	if t.index >= 0 {
+		heap.Remove(&q.timers, t.index)
+	}
+	return true
+}
+
+// Len returns the number of registered timers.
+func (q *TimerQueue) Len() int {
+	return len(q.byID)
+}
+
+// Timeout returns how long the loop may wait before the nearest timer is due,
+// or a negative duration when there is no timer at all.
+func (q *TimerQueue) Timeout(now time.Time) time.Duration {
+	if len(q.timers) == 0 {
+		return -1
+	}
+	return max(q.timers[0].when.Sub(now), 0)
+}
+
+// Process runs the callbacks of every timer due at now and returns how many ran.
+// Timers registered by the callbacks run on a later call at the earliest, so a
+// callback rescheduling itself with no delay cannot starve the event loop.
+func (q *TimerQueue) Process(now time.Time) int {
+	var due []*Timer
+	for len(q.timers) > 0 && !q.timers[0].when.After(now) {
+		due = append(due, heap.Pop(&q.timers).(*Timer))
+	}
+
+	processed := 0
+	for _, t := range due {
+		if t.removed {
+			// removed by the callback of a timer due at the same time
+			continue
+		}
+		t.callback(now)
+		processed++
+		if t.removed {
+			continue
+		}
+		if t.period > 0 {
+			t.when = now.Add(t.period)
+			heap.Push(&q.timers, t)
+		} else {
+			delete(q.byID, t.id)
+		}
+	}
+	return processed
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerQueue_Order(t *testing.T) {
+	q := NewTimerQueue()
+	var fired []int
+	q.AddOnce(30*time.Millisecond, func(time.Time) { fired = append(fired, 3) })
+	q.AddOnce(10*time.Millisecond, func(time.Time) { fired = append(fired, 1) })
+	q.AddOnce(20*time.Millisecond, func(time.Time) { fired = append(fired, 2) })
+
+	now := time.Now()
+	assert.True(t, q.Timeout(now) <= 10*time.Millisecond)
+	assert.EqualValues(t, 0, q.Process(now))
+
+	assert.EqualValues(t, 2, q.Process(now.Add(25*time.Millisecond)))
+	assert.EqualValues(t, []int{1, 2}, fired)
+	assert.EqualValues(t, 1, q.Len())
+
+	assert.EqualValues(t, 1, q.Process(now.Add(time.Second)))
+	assert.EqualValues(t, []int{1, 2, 3}, fired)
+	assert.EqualValues(t, 0, q.Len())
+	assert.True(t, q.Timeout(now) < 0)
+}
+
+func TestTimerQueue_Repeating(t *testing.T) {
+	q := NewTimerQueue()
+	count := 0
+	var id int64
+	id = q.AddRepeating(10*time.Millisecond, func(time.Time) {
+		count++
+		if count == 3 {
+			q.Remove(id)
+		}
+	})
+
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		now = now.Add(10 * time.Millisecond)
+		q.Process(now)
+	}
+	assert.EqualValues(t, 3, count)
+	assert.EqualValues(t, 0, q.Len())
+}
+
+func TestTimerQueue_Remove(t *testing.T) {
+	q := NewTimerQueue()
+	fired := false
+	var second int64
+	q.AddOnce(0, func(time.Time) { q.Remove(second) })
+	second = q.AddOnce(0, func(time.Time) { fired = true })
+
+	assert.EqualValues(t, 1, q.Process(time.Now().Add(time.Millisecond)))
+	assert.False(t, fired)
+	assert.False(t, q.Remove(second))
+	assert.EqualValues(t, 0, q.Len())
+}
@@ -8,6 +8,8 @@ func (e Event) toNative() syscall.EpollEvent {
 	event := syscall.EPOLLIN
 	if e.Op == OperationWrite {
 		event = syscall.EPOLLOUT
+	} else if e.Op == OperationReadWrite {
+		event = syscall.EPOLLIN | syscall.EPOLLOUT
 	}
 
 	return syscall.EpollEvent{
@@ -17,8 +19,17 @@ func (e Event) toNative() syscall.EpollEvent {
 }
 
 func createEvent(ep syscall.EpollEvent) Event {
-	op := OperationRead
-	if ep.Events == syscall.EPOLLOUT {
+	// a half-closed peer reports EPOLLHUP alongside the input it sent last, so readable
+	// wins and the read that follows observes EOF
+	op := OperationError
+	readable := ep.Events&syscall.EPOLLIN != 0
+	writable := ep.Events&syscall.EPOLLOUT != 0
+	failed := ep.Events&(syscall.EPOLLERR|syscall.EPOLLHUP) != 0
+	if readable && writable {
+		op = OperationReadWrite
+	} else if readable {
+		op = OperationRead
+	} else if writable && !failed {
 		op = OperationWrite
 	}
 
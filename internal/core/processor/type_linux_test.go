@@ -0,0 +1,29 @@
+//go:build linux
+
+package processor
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEvent(t *testing.T) {
+	cases := map[uint32]Operation{
+		syscall.EPOLLIN:                                       OperationRead,
+		syscall.EPOLLOUT:                                      OperationWrite,
+		syscall.EPOLLIN | syscall.EPOLLOUT:                    OperationReadWrite,
+		syscall.EPOLLIN | syscall.EPOLLHUP:                    OperationRead,
+		syscall.EPOLLIN | syscall.EPOLLOUT | syscall.EPOLLHUP: OperationReadWrite,
+		syscall.EPOLLIN | syscall.EPOLLERR:                    OperationRead,
+		syscall.EPOLLHUP:                                      OperationError,
+		syscall.EPOLLERR:                                      OperationError,
+		syscall.EPOLLOUT | syscall.EPOLLERR:                   OperationError,
+	}
+	for events, expected := range cases {
+		event := createEvent(syscall.EpollEvent{Fd: 7, Events: events})
+		assert.EqualValues(t, 7, event.Fd)
+		assert.EqualValues(t, expected, event.Op, "events %#x", events)
+	}
+}
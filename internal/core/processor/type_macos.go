@@ -22,6 +22,10 @@ func createEvent(kq syscall.Kevent_t) Event {
 	if kq.Filter == syscall.EVFILT_READ {
 		op = OperationRead
 	}
+	// EV_EOF on a read filter with data left still lets us read the rest of the input first
+	if kq.Flags&syscall.EV_ERROR != 0 || (kq.Flags&syscall.EV_EOF != 0 && kq.Data == 0) {
+		op = OperationError
+	}
 	return Event{
 		Fd: int(kq.Ident),
 		Op: op,
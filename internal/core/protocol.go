@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"strings"
 
 	"memkv/internal/constants"
@@ -11,9 +13,28 @@ import (
 
 const CRLF string = "\r\n"
 
+const (
+	maxBulkLength  = 512 * 1024 * 1024
+	maxArrayLength = 1024 * 1024
+)
+
+var (
+	// ErrIncompleteFrame is returned while the buffer does not yet hold a whole RESP frame.
+	// The caller should keep the bytes and retry once more data has been read.
+	ErrIncompleteFrame = errors.New("incomplete RESP frame")
+
+	ErrProtocolInvalidType        = errors.New("ERR Protocol error: invalid type prefix")
+	ErrProtocolInvalidInteger     = errors.New("ERR Protocol error: invalid integer")
+	ErrProtocolInvalidBulkLength  = errors.New("ERR Protocol error: invalid bulk length")
+	ErrProtocolInvalidArrayLength = errors.New("ERR Protocol error: invalid multibulk length")
+	ErrProtocolExpectedBulk       = errors.New("ERR Protocol error: expected '$'")
+	ErrProtocolMissingCRLF        = errors.New("ERR Protocol error: missing CRLF")
+	ErrProtocolQueryBufferLimit   = errors.New("ERR Protocol error: query buffer limit exceeded")
+)
+
 func DecodeOne(data []byte) (interface{}, int, error) {
 	if len(data) == 0 {
-		return nil, 0, errors.New("no data")
+		return nil, 0, ErrIncompleteFrame
 	}
 	switch data[0] {
 	case '+':
@@ -29,7 +50,7 @@ func DecodeOne(data []byte) (interface{}, int, error) {
 	case '@':
 		return readIntArray(data)
 	}
-	return nil, 0, nil
+	return nil, 0, ErrProtocolInvalidType
 }
 
 func Decode(data []byte) (interface{}, error) {
@@ -48,6 +69,10 @@ func Encode(value interface{}, isSimpleString bool) []byte {
 	case int64, int32, int16, int8, int:
 		return []byte(fmt.Sprintf(":%d\r\n", v))
 
+	case float64:
+		// RESP2 has no double type, doubles are sent as bulk strings in the RESP3 format
+		return encodeString(formatDouble(v))
+
 	case error:
 		return []byte(fmt.Sprintf("-%s\r\n", v))
 
@@ -88,34 +113,199 @@ func ParseCmd(data []byte) (*MemkvCommand, error) {
 	if err != nil {
 		return nil, err
 	}
+	return toCommand(value)
+}
 
-	array := value.([]interface{})
+func toCommand(value interface{}) (*MemkvCommand, error) {
+	array, ok := value.([]interface{})
+	if !ok || len(array) == 0 {
+		return nil, ErrProtocolExpectedBulk
+	}
 	tokens := make([]string, len(array))
 	for i := range tokens {
-		tokens[i] = array[i].(string)
+		token, ok := array[i].(string)
+		if !ok {
+			return nil, ErrProtocolExpectedBulk
+		}
+		tokens[i] = token
 	}
 	res := &MemkvCommand{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
 	return res, nil
 }
 
+// RespDecoder is a per-connection incremental decoder. Bytes read from the socket are fed
+// into it as they arrive and commands are handed out only once their frame is complete,
+// so a command split across several reads or several commands coalesced into one read
+// are both handled. The headers and bulks already parsed are consumed as they arrive, so a
+// large frame received in many reads is not parsed again from its start on every read.
+type RespDecoder struct {
+	buf []byte
+	pos int // start of the first unconsumed byte in buf
+
+	// state of the command being parsed
+	multibulkLen int // arguments still expected, 0 before the array header is read
+	bulkLen      int // length of the next argument, -1 before its header is read
+	args         []string
+}
+
+// respMaxHeaderLength bounds the array and bulk headers, longer ones are malformed
+const respMaxHeaderLength = 64 * 1024
+
+// respBigArg is the argument length past which the buffer is sized to hold it at once
+const respBigArg = 32 * 1024
+
+func NewRespDecoder() *RespDecoder {
+	return &RespDecoder{bulkLen: -1}
+}
+
+// Feed appends data read from the connection to the pending buffer.
+func (d *RespDecoder) Feed(data []byte) {
+	if d.pos > 0 {
+		// drop the consumed prefix before growing the buffer
+		n := copy(d.buf, d.buf[d.pos:])
+		d.buf = d.buf[:n]
+		d.pos = 0
+	}
+	d.buf = append(d.buf, data...)
+}
+
+// Buffered returns the number of bytes received but not consumed yet.
+func (d *RespDecoder) Buffered() int {
+	return len(d.buf) - d.pos
+}
+
+// Next decodes the next complete command from the pending buffer. It returns
+// ErrIncompleteFrame when more data is needed; any other error means the stream is
+// malformed and the connection should be dropped.
+func (d *RespDecoder) Next() (*MemkvCommand, error) {
+	for d.multibulkLen == 0 {
+		length, err := d.readHeader('*')
+		if err != nil {
+			return nil, err
+		}
+		if length > maxArrayLength {
+			return nil, ErrProtocolInvalidArrayLength
+		}
+		// clients may send empty or null arrays, skip them like Redis does
+		if length > 0 {
+			d.multibulkLen = length
+			// the header alone may be all we have, don't trust it for a large allocation
+			d.args = make([]string, 0, min(length, 1024))
+		}
+	}
+
+	for d.multibulkLen > 0 {
+		if d.bulkLen == -1 {
+			length, err := d.readHeader('$')
+			if err != nil {
+				return nil, err
+			}
+			if length < 0 || length > maxBulkLength {
+				return nil, ErrProtocolInvalidBulkLength
+			}
+			d.bulkLen = length
+			// the argument may already be buffered, e.g. when a blocked client kept pipelining
+			if missing := d.pos + length + 2 - len(d.buf); length >= respBigArg && missing > 0 {
+				d.buf = slices.Grow(d.buf, missing)
+			}
+		}
+		if d.Buffered() < d.bulkLen+2 {
+			return nil, ErrIncompleteFrame
+		}
+		end := d.pos + d.bulkLen
+		if d.buf[end] != '\r' || d.buf[end+1] != '\n' {
+			return nil, ErrProtocolMissingCRLF
+		}
+		d.args = append(d.args, string(d.buf[d.pos:end]))
+		d.consume(d.bulkLen + 2)
+		d.bulkLen = -1
+		d.multibulkLen--
+	}
+
+	args := d.args
+	d.args = nil
+	return &MemkvCommand{Cmd: strings.ToUpper(args[0]), Args: args[1:]}, nil
+}
+
+// readHeader consumes a "<prefix><integer>\r\n" line and returns the integer
+func (d *RespDecoder) readHeader(prefix byte) (int, error) {
+	data := d.buf[d.pos:]
+	if len(data) == 0 {
+		return 0, ErrIncompleteFrame
+	}
+	if data[0] != prefix {
+		switch data[0] {
+		case '+', '-', ':', '$', '*':
+			return 0, ErrProtocolExpectedBulk
+		}
+		return 0, ErrProtocolInvalidType
+	}
+	length, n, err := readLen(data)
+	if err == ErrIncompleteFrame && len(data) > respMaxHeaderLength {
+		err = ErrProtocolInvalidInteger
+	}
+	if err == ErrProtocolInvalidInteger {
+		if prefix == '*' {
+			err = ErrProtocolInvalidArrayLength
+		} else {
+			err = ErrProtocolInvalidBulkLength
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	d.consume(n)
+	return length, nil
+}
+
+// consume marks n bytes as parsed, releasing the buffer once it was all parsed
+func (d *RespDecoder) consume(n int) {
+	d.pos += n
+	if d.pos == len(d.buf) {
+		d.buf = d.buf[:0]
+		d.pos = 0
+	}
+}
+
 // +OK\r\n => OK, 5
 func readSimpleString(data []byte) (string, int, error) {
-	pos := 1
-	for data[pos] != '\r' {
-		pos++
+	end := bytes.Index(data, []byte(CRLF))
+	if end < 0 {
+		return "", 0, ErrIncompleteFrame
 	}
-	return string(data[1:pos]), pos + 2, nil
+	return string(data[1:end]), end + 2, nil
 }
 
 // :123\r\n => 123
 func readInt64(data []byte) (int64, int, error) {
-	var res int64 = 0
+	end := bytes.Index(data, []byte(CRLF))
+	if end < 0 {
+		return 0, 0, ErrIncompleteFrame
+	}
 	pos := 1
-	for data[pos] != '\r' {
-		res = res*10 + int64(data[pos]-'0')
+	negative := false
+	if pos < end && (data[pos] == '-' || data[pos] == '+') {
+		negative = data[pos] == '-'
 		pos++
 	}
-	return res, pos + 2, nil
+	if pos == end || end-pos > 19 {
+		return 0, 0, ErrProtocolInvalidInteger
+	}
+	var res int64 = 0
+	for ; pos < end; pos++ {
+		if data[pos] < '0' || data[pos] > '9' {
+			return 0, 0, ErrProtocolInvalidInteger
+		}
+		d := int64(data[pos] - '0')
+		if res > (math.MaxInt64-d)/10 {
+			return 0, 0, ErrProtocolInvalidInteger
+		}
+		res = res*10 + d
+	}
+	if negative {
+		res = -res
+	}
+	return res, end + 2, nil
 }
 
 func readError(data []byte) (string, int, error) {
@@ -123,28 +313,60 @@ func readError(data []byte) (string, int, error) {
 }
 
 // $5\r\nhello\r\n => 5, 4
-func readLen(data []byte) (int, int) {
-	res, pos, _ := readInt64(data)
-	return int(res), pos
+func readLen(data []byte) (int, int, error) {
+	res, pos, err := readInt64(data)
+	return int(res), pos, err
 }
 
 // $5\r\nhello\r\n => "hello"
-func readBulkString(data []byte) (string, int, error) {
-	length, pos := readLen(data)
+// $-1\r\n => nil
+func readBulkString(data []byte) (interface{}, int, error) {
+	length, pos, err := readLen(data)
+	if err != nil {
+		if err == ErrProtocolInvalidInteger {
+			err = ErrProtocolInvalidBulkLength
+		}
+		return nil, 0, err
+	}
+	if length == -1 {
+		return nil, pos, nil
+	}
+	if length < 0 || length > maxBulkLength {
+		return nil, 0, ErrProtocolInvalidBulkLength
+	}
+	if len(data) < pos+length+2 {
+		return nil, 0, ErrIncompleteFrame
+	}
+	if data[pos+length] != '\r' || data[pos+length+1] != '\n' {
+		return nil, 0, ErrProtocolMissingCRLF
+	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
-	length, pos := readLen(data)
-	var res []interface{} = make([]interface{}, length)
+	length, pos, err := readLen(data)
+	if err != nil {
+		if err == ErrProtocolInvalidInteger {
+			err = ErrProtocolInvalidArrayLength
+		}
+		return nil, 0, err
+	}
+	if length == -1 {
+		return nil, pos, nil
+	}
+	if length < 0 || length > maxArrayLength {
+		return nil, 0, ErrProtocolInvalidArrayLength
+	}
+	// the header alone may be all we have, don't trust it for a large allocation
+	var res []interface{} = make([]interface{}, 0, min(length, 1024))
 
-	for i := range res {
+	for i := 0; i < length; i++ {
 		elem, delta, err := DecodeOne(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
-		res[i] = elem
+		res = append(res, elem)
 		pos += delta
 	}
 	return res, pos, nil
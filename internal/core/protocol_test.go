@@ -0,0 +1,157 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespDecoder_PartialFrame(t *testing.T) {
+	d := NewRespDecoder()
+	frame := "*3\r\n$4\r\nZADD\r\n$2\r\nlb\r\n$5\r\nalice\r\n"
+
+	// feed the frame byte by byte, nothing must come out before the last byte
+	for i := 0; i < len(frame)-1; i++ {
+		d.Feed([]byte{frame[i]})
+		cmd, err := d.Next()
+		assert.Nil(t, cmd)
+		assert.ErrorIs(t, err, ErrIncompleteFrame)
+	}
+
+	d.Feed([]byte{frame[len(frame)-1]})
+	cmd, err := d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "ZADD", cmd.Cmd)
+	assert.EqualValues(t, []string{"lb", "alice"}, cmd.Args)
+	assert.EqualValues(t, 0, d.Buffered())
+}
+
+func TestRespDecoder_CoalescedFrames(t *testing.T) {
+	d := NewRespDecoder()
+	d.Feed([]byte("*1\r\n$4\r\nPING\r\n*0\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n*1\r\n$3\r\nGE"))
+
+	cmd, err := d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "PING", cmd.Cmd)
+
+	// the empty array is skipped
+	cmd, err = d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "ECHO", cmd.Cmd)
+	assert.EqualValues(t, []string{"hi"}, cmd.Args)
+
+	_, err = d.Next()
+	assert.ErrorIs(t, err, ErrIncompleteFrame)
+
+	d.Feed([]byte("T\r\n"))
+	cmd, err = d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "GET", cmd.Cmd)
+}
+
+func TestRespDecoder_LargeBulk(t *testing.T) {
+	d := NewRespDecoder()
+	member := make([]byte, 4096)
+	for i := range member {
+		member[i] = 'a' + byte(i%26)
+	}
+	frame := Encode([]string{"ZADD", "lb", "1", string(member)}, false)
+
+	d.Feed(frame[:512])
+	_, err := d.Next()
+	assert.ErrorIs(t, err, ErrIncompleteFrame)
+
+	d.Feed(frame[512:])
+	cmd, err := d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, string(member), cmd.Args[2])
+}
+
+func TestRespDecoder_KeepsPartialState(t *testing.T) {
+	d := NewRespDecoder()
+	member := strings.Repeat("x", 100000)
+	frame := Encode([]string{"SET", "k", member}, false)
+
+	// the headers and the complete arguments are consumed, only the partial bulk stays
+	d.Feed(frame[:1000])
+	_, err := d.Next()
+	assert.ErrorIs(t, err, ErrIncompleteFrame)
+	assert.EqualValues(t, []string{"SET", "k"}, d.args)
+	assert.EqualValues(t, 1, d.multibulkLen)
+	assert.EqualValues(t, len(member), d.bulkLen)
+	assert.EqualValues(t, 1000-(len(frame)-len(member)-2), d.Buffered())
+
+	for i := 1000; i < len(frame); i += 1000 {
+		d.Feed(frame[i:min(i+1000, len(frame))])
+		cmd, err := d.Next()
+		if i+1000 < len(frame) {
+			assert.ErrorIs(t, err, ErrIncompleteFrame)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.EqualValues(t, "SET", cmd.Cmd)
+		assert.EqualValues(t, member, cmd.Args[1])
+	}
+	assert.EqualValues(t, 0, d.Buffered())
+}
+
+func TestRespDecoder_LargeBulkAlreadyBuffered(t *testing.T) {
+	d := NewRespDecoder()
+	value := strings.Repeat("v", 40000)
+	frame := append(Encode([]string{"SET", "k", value}, false), Encode([]string{"GET", "k"}, false)...)
+	d.Feed(frame)
+
+	cmd, err := d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SET", cmd.Cmd)
+	assert.EqualValues(t, value, cmd.Args[1])
+	cmd, err = d.Next()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "GET", cmd.Cmd)
+	assert.EqualValues(t, 0, d.Buffered())
+}
+
+func TestRespDecoder_HeaderTooLong(t *testing.T) {
+	d := NewRespDecoder()
+	d.Feed([]byte("*1\r\n$" + strings.Repeat("1", respMaxHeaderLength+1)))
+	_, err := d.Next()
+	assert.ErrorIs(t, err, ErrProtocolInvalidBulkLength)
+}
+
+func TestRespDecoder_ProtocolErrors(t *testing.T) {
+	cases := map[string]error{
+		"*1\r\n$x\r\n":                   ErrProtocolInvalidBulkLength,
+		"*abc\r\n":                       ErrProtocolInvalidArrayLength,
+		"*1\r\n$2\r\nabcd\r\n":           ErrProtocolMissingCRLF,
+		"*1\r\n:1\r\n":                   ErrProtocolExpectedBulk,
+		"!bad\r\n":                       ErrProtocolInvalidType,
+		"*1\r\n$-5\r\nabc\r\n":           ErrProtocolInvalidBulkLength,
+		"*99999999999\r\n":               ErrProtocolInvalidArrayLength,
+		"*9999999999999999999\r\n":       ErrProtocolInvalidArrayLength,
+		"*1\r\n$9999999999999999999\r\n": ErrProtocolInvalidBulkLength,
+	}
+	for input, expected := range cases {
+		d := NewRespDecoder()
+		d.Feed([]byte(input))
+		_, err := d.Next()
+		assert.ErrorIs(t, err, expected, input)
+	}
+}
+
+func TestReadInt64_Overflow(t *testing.T) {
+	res, n, err := readInt64([]byte(":9223372036854775807\r\n"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, int64(math.MaxInt64), res)
+	assert.EqualValues(t, 22, n)
+
+	_, _, err = readInt64([]byte(":9223372036854775808\r\n"))
+	assert.ErrorIs(t, err, ErrProtocolInvalidInteger)
+	_, _, err = readInt64([]byte(":9999999999999999999\r\n"))
+	assert.ErrorIs(t, err, ErrProtocolInvalidInteger)
+}
+
+func TestEncodeDouble(t *testing.T) {
+	assert.EqualValues(t, "$4\r\n1e-9\r\n", string(Encode(1e-9, false)))
+}
@@ -0,0 +1,315 @@
+package core
+
+import "memkv/internal/config"
+
+// Quicklist is a doubly linked list of listpacks. Each node holds a bounded chunk of
+// entries, which keeps the per-element overhead of the listpack while pushes and pops at
+// both ends stay O(1) and inserting in the middle only rewrites one small node.
+type Quicklist struct {
+	head, tail *quicklistNode
+	count      int // number of entries in all the nodes
+	nodes      int
+}
+
+type quicklistNode struct {
+	prev, next *quicklistNode
+	lp         *Listpack
+}
+
+// quicklistSizeLimits maps the negative values of config.ListMaxListpackSize to the
+// largest size in bytes of a node
+var quicklistSizeLimits = []int{4096, 8192, 16384, 32768, 65536}
+
+// quicklistMaxEntries bounds the entries of a node when its size is limited in bytes, so a
+// list of tiny elements does not end up in huge nodes either
+const quicklistMaxEntries = 1024
+
+func NewQuicklist() *Quicklist {
+	return &Quicklist{}
+}
+
+// Len returns the number of entries
+func (ql *Quicklist) Len() int {
+	return ql.count
+}
+
+// nodeAllowsInsert tells whether value fits in node without going over the node limits
+func nodeAllowsInsert(node *quicklistNode, value string) bool {
+	if node == nil {
+		return false
+	}
+	fill := config.ListMaxListpackSize
+	if fill >= 0 {
+		return node.lp.Len() < max(fill, 1)
+	}
+	limit := quicklistSizeLimits[min(-fill, len(quicklistSizeLimits))-1]
+	// the entry overhead is at most a few bytes, this errs on the safe side
+	return node.lp.Len() < quicklistMaxEntries && node.lp.Bytes()+len(value)+11 <= limit
+}
+
+// nodeIsOverLimit tells whether node grew past the limits and must be split
+func nodeIsOverLimit(node *quicklistNode) bool {
+	if node.lp.Len() <= 1 {
+		// a single element larger than the limit gets a node of its own
+		return false
+	}
+	fill := config.ListMaxListpackSize
+	if fill >= 0 {
+		return node.lp.Len() > max(fill, 1)
+	}
+	limit := quicklistSizeLimits[min(-fill, len(quicklistSizeLimits))-1]
+	return node.lp.Len() > quicklistMaxEntries || node.lp.Bytes() > limit
+}
+
+func (ql *Quicklist) insertNodeAfter(prev *quicklistNode, node *quicklistNode) {
+	node.prev = prev
+	if prev == nil {
+		node.next = ql.head
+		ql.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next == nil {
+		ql.tail = node
+	} else {
+		node.next.prev = node
+	}
+	ql.nodes++
+}
+
+func (ql *Quicklist) removeNode(node *quicklistNode) {
+	if node.prev == nil {
+		ql.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		ql.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	ql.nodes--
+}
+
+// PushHead adds value before the first entry
+func (ql *Quicklist) PushHead(value string) {
+	if !nodeAllowsInsert(ql.head, value) {
+		ql.insertNodeAfter(nil, &quicklistNode{lp: NewListpack()})
+	}
+	ql.head.lp.Insert(0, value)
+	ql.count++
+}
+
+// PushTail adds value after the last entry
+func (ql *Quicklist) PushTail(value string) {
+	if !nodeAllowsInsert(ql.tail, value) {
+		ql.insertNodeAfter(ql.tail, &quicklistNode{lp: NewListpack()})
+	}
+	ql.tail.lp.Append(value)
+	ql.count++
+}
+
+// locate returns the node holding the entry at index and the offset of the entry in the
+// node, walking from the closest end. index must be in range.
+func (ql *Quicklist) locate(index int) (*quicklistNode, int) {
+	if index < ql.count/2 {
+		node := ql.head
+		for index >= node.lp.Len() {
+			index -= node.lp.Len()
+			node = node.next
+		}
+		return node, index
+	}
+	node := ql.tail
+	index = ql.count - 1 - index
+	for index >= node.lp.Len() {
+		index -= node.lp.Len()
+		node = node.prev
+	}
+	return node, node.lp.Len() - 1 - index
+}
+
+// normalizeIndex turns a negative index, counted from the tail, into a positive one. It
+// returns false when the index is out of range.
+func (ql *Quicklist) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index += ql.count
+	}
+	return index, index >= 0 && index < ql.count
+}
+
+// Index returns the entry at index, negative indexes count from the tail
+func (ql *Quicklist) Index(index int) (string, bool) {
+	index, ok := ql.normalizeIndex(index)
+	if !ok {
+		return "", false
+	}
+	node, offset := ql.locate(index)
+	return node.lp.Get(node.lp.Seek(offset)), true
+}
+
+// Replace overwrites the entry at index, it returns false when index is out of range
+func (ql *Quicklist) Replace(index int, value string) bool {
+	index, ok := ql.normalizeIndex(index)
+	if !ok {
+		return false
+	}
+	node, offset := ql.locate(index)
+	node.lp.Replace(offset, value)
+	ql.splitIfNeeded(node)
+	return true
+}
+
+// Insert adds value before the entry at index, index can be Len() to append
+func (ql *Quicklist) Insert(index int, value string) {
+	switch {
+	case index <= 0:
+		ql.PushHead(value)
+		return
+	case index >= ql.count:
+		ql.PushTail(value)
+		return
+	}
+	node, offset := ql.locate(index)
+	if offset == 0 && nodeAllowsInsert(node.prev, value) {
+		// at a node boundary, fill the previous node rather than growing this one
+		node = node.prev
+		offset = node.lp.Len()
+	}
+	node.lp.Insert(offset, value)
+	ql.count++
+	ql.splitIfNeeded(node)
+}
+
+// splitIfNeeded cuts a node that grew past the limits in two halves
+func (ql *Quicklist) splitIfNeeded(node *quicklistNode) {
+	if !nodeIsOverLimit(node) {
+		return
+	}
+	half := node.lp.Len() / 2
+	next := &quicklistNode{lp: NewListpack()}
+	for p := node.lp.Seek(half); p != -1; p = node.lp.Next(p) {
+		next.lp.Append(node.lp.Get(p))
+	}
+	node.lp.Delete(half, node.lp.Len()-half)
+	ql.insertNodeAfter(node, next)
+}
+
+// DeleteRange removes count entries starting at index, it returns how many were removed
+func (ql *Quicklist) DeleteRange(index int, count int) int {
+	index, ok := ql.normalizeIndex(index)
+	if !ok || count <= 0 {
+		return 0
+	}
+	node, offset := ql.locate(index)
+	deleted := 0
+	for node != nil && deleted < count {
+		next := node.next
+		n := min(count-deleted, node.lp.Len()-offset)
+		if offset == 0 && n == node.lp.Len() {
+			ql.removeNode(node)
+		} else {
+			node.lp.Delete(offset, n)
+		}
+		deleted += n
+		offset = 0
+		node = next
+	}
+	ql.count -= deleted
+	return deleted
+}
+
+// Range returns the entries between the indexes start and end, both included, which must
+// be in range
+func (ql *Quicklist) Range(start int, end int) []string {
+	if start > end || start >= ql.count {
+		return []string{}
+	}
+	res := make([]string, 0, end-start+1)
+	node, offset := ql.locate(start)
+	for ; node != nil && len(res) < end-start+1; node, offset = node.next, 0 {
+		for p := node.lp.Seek(offset); p != -1 && len(res) < end-start+1; p = node.lp.Next(p) {
+			res = append(res, node.lp.Get(p))
+		}
+	}
+	return res
+}
+
+// ForEach calls fn with the index and the value of every entry from the head, or from the
+// tail with reverse, until fn returns false
+func (ql *Quicklist) ForEach(reverse bool, fn func(index int, value string) bool) {
+	if !reverse {
+		index := 0
+		for node := ql.head; node != nil; node = node.next {
+			for p := node.lp.First(); p != -1; p = node.lp.Next(p) {
+				if !fn(index, node.lp.Get(p)) {
+					return
+				}
+				index++
+			}
+		}
+		return
+	}
+	index := ql.count - 1
+	for node := ql.tail; node != nil; node = node.prev {
+		for p := node.lp.Last(); p != -1; p = node.lp.Prev(p) {
+			if !fn(index, node.lp.Get(p)) {
+				return
+			}
+			index--
+		}
+	}
+}
+
+// Remove deletes up to limit entries equal to value, all of them if limit is 0, starting
+// from the tail with reverse. It returns how many were removed.
+func (ql *Quicklist) Remove(value string, limit int, reverse bool) int {
+	removed := 0
+	node := ql.head
+	if reverse {
+		node = ql.tail
+	}
+	for node != nil && (limit == 0 || removed < limit) {
+		next := node.next
+		if reverse {
+			next = node.prev
+		}
+		lp := node.lp
+		if !reverse {
+			for p := lp.First(); p != -1 && (limit == 0 || removed < limit); {
+				if lp.Get(p) == value {
+					p = lp.DeleteAt(p)
+					removed++
+				} else {
+					p = lp.Next(p)
+				}
+			}
+		} else {
+			for p := lp.Last(); p != -1 && (limit == 0 || removed < limit); {
+				prev := lp.Prev(p)
+				if lp.Get(p) == value {
+					lp.DeleteAt(p)
+					removed++
+				}
+				p = prev
+			}
+		}
+		if lp.Len() == 0 {
+			ql.removeNode(node)
+		}
+		node = next
+	}
+	ql.count -= removed
+	return removed
+}
+
+// Dup returns a copy of the list
+func (ql *Quicklist) Dup() *Quicklist {
+	dup := NewQuicklist()
+	for node := ql.head; node != nil; node = node.next {
+		dup.insertNodeAfter(dup.tail, &quicklistNode{lp: node.lp.Dup()})
+	}
+	dup.count = ql.count
+	return dup
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"memkv/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withListNodeSize runs fn with nodes of at most size entries, small sizes make every test
+// go through node boundaries and splits
+func withListNodeSize(size int, fn func()) {
+	saved := config.ListMaxListpackSize
+	config.ListMaxListpackSize = size
+	defer func() { config.ListMaxListpackSize = saved }()
+	fn()
+}
+
+func quicklistValues(ql *Quicklist) []string {
+	res := []string{}
+	ql.ForEach(false, func(_ int, value string) bool {
+		res = append(res, value)
+		return true
+	})
+	return res
+}
+
+// checkQuicklist verifies the links and the counters of ql
+func checkQuicklist(t *testing.T, ql *Quicklist) {
+	count, nodes := 0, 0
+	var prev *quicklistNode
+	for node := ql.head; node != nil; node = node.next {
+		assert.Equal(t, prev, node.prev)
+		assert.Greater(t, node.lp.Len(), 0)
+		count += node.lp.Len()
+		nodes++
+		prev = node
+	}
+	assert.Equal(t, prev, ql.tail)
+	assert.EqualValues(t, ql.count, count)
+	assert.EqualValues(t, ql.nodes, nodes)
+}
+
+func createNumberedQuicklist(n int) *Quicklist {
+	ql := NewQuicklist()
+	for i := 0; i < n; i++ {
+		ql.PushTail(fmt.Sprintf("v%d", i))
+	}
+	return ql
+}
+
+func TestQuicklist_Push(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := NewQuicklist()
+		for i := 0; i < 10; i++ {
+			ql.PushTail(fmt.Sprint(i))
+			ql.PushHead(fmt.Sprint(-i - 1))
+		}
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, 20, ql.Len())
+		assert.EqualValues(t, 5, ql.nodes)
+		values := quicklistValues(ql)
+		assert.EqualValues(t, "-10", values[0])
+		assert.EqualValues(t, "9", values[19])
+
+		var reverse []string
+		ql.ForEach(true, func(index int, value string) bool {
+			assert.EqualValues(t, values[index], value)
+			reverse = append(reverse, value)
+			return true
+		})
+		assert.EqualValues(t, 20, len(reverse))
+	})
+}
+
+func TestQuicklist_SizeLimitInBytes(t *testing.T) {
+	withListNodeSize(-1, func() {
+		ql := NewQuicklist()
+		for i := 0; i < 10; i++ {
+			ql.PushTail(strings.Repeat("x", 1000))
+		}
+		checkQuicklist(t, ql)
+		// 4kb nodes hold 4 values of 1kb at most
+		assert.GreaterOrEqual(t, ql.nodes, 3)
+		for node := ql.head; node != nil; node = node.next {
+			assert.LessOrEqual(t, node.lp.Bytes(), 4096)
+		}
+
+		// a value larger than the limit gets a node of its own
+		ql.PushTail(strings.Repeat("y", 5000))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, 1, ql.tail.lp.Len())
+	})
+}
+
+func TestQuicklist_IndexAndReplace(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := createNumberedQuicklist(10)
+		for i := 0; i < 10; i++ {
+			v, ok := ql.Index(i)
+			assert.True(t, ok)
+			assert.EqualValues(t, fmt.Sprintf("v%d", i), v)
+			v, ok = ql.Index(i - 10)
+			assert.True(t, ok)
+			assert.EqualValues(t, fmt.Sprintf("v%d", i), v)
+		}
+		_, ok := ql.Index(10)
+		assert.False(t, ok)
+		_, ok = ql.Index(-11)
+		assert.False(t, ok)
+
+		assert.True(t, ql.Replace(-1, "last"))
+		assert.True(t, ql.Replace(5, "five"))
+		assert.False(t, ql.Replace(10, "x"))
+		v, _ := ql.Index(9)
+		assert.EqualValues(t, "last", v)
+		v, _ = ql.Index(5)
+		assert.EqualValues(t, "five", v)
+		checkQuicklist(t, ql)
+	})
+}
+
+func TestQuicklist_Insert(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := NewQuicklist()
+		var expected []string
+		for i := 0; i < 30; i++ {
+			// insert in the middle, at node boundaries and at both ends
+			index := (i * 7) % (ql.Len() + 1)
+			value := fmt.Sprint(i)
+			ql.Insert(index, value)
+			expected = append(expected[:index], append([]string{value}, expected[index:]...)...)
+			checkQuicklist(t, ql)
+			assert.EqualValues(t, expected, quicklistValues(ql))
+		}
+		for node := ql.head; node != nil; node = node.next {
+			assert.LessOrEqual(t, node.lp.Len(), 4)
+		}
+	})
+}
+
+func TestQuicklist_DeleteRange(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := createNumberedQuicklist(20)
+		// across three nodes
+		assert.EqualValues(t, 7, ql.DeleteRange(3, 7))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, []string{"v0", "v1", "v2", "v10", "v11"}, quicklistValues(ql)[:5])
+
+		// from a negative index, count going past the tail
+		assert.EqualValues(t, 2, ql.DeleteRange(-2, 10))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, 11, ql.Len())
+		v, _ := ql.Index(-1)
+		assert.EqualValues(t, "v17", v)
+
+		assert.EqualValues(t, 0, ql.DeleteRange(11, 1))
+		assert.EqualValues(t, 0, ql.DeleteRange(0, 0))
+		assert.EqualValues(t, 11, ql.DeleteRange(0, 11))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, 0, ql.Len())
+		assert.Nil(t, ql.head)
+	})
+}
+
+func TestQuicklist_Range(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := createNumberedQuicklist(10)
+		assert.EqualValues(t, []string{"v3", "v4", "v5", "v6", "v7"}, ql.Range(3, 7))
+		assert.EqualValues(t, []string{"v9"}, ql.Range(9, 9))
+		assert.EqualValues(t, 10, len(ql.Range(0, 9)))
+		assert.EqualValues(t, []string{}, ql.Range(5, 4))
+	})
+}
+
+func TestQuicklist_Remove(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := NewQuicklist()
+		for i := 0; i < 12; i++ {
+			ql.PushTail("a")
+			ql.PushTail(fmt.Sprint(i))
+		}
+
+		assert.EqualValues(t, 2, ql.Remove("a", 2, false))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, []string{"0", "1", "a", "2"}, quicklistValues(ql)[:4])
+
+		assert.EqualValues(t, 3, ql.Remove("a", 3, true))
+		checkQuicklist(t, ql)
+		values := quicklistValues(ql)
+		assert.EqualValues(t, []string{"a", "8", "9", "10", "11"}, values[len(values)-5:])
+
+		assert.EqualValues(t, 7, ql.Remove("a", 0, false))
+		checkQuicklist(t, ql)
+		assert.EqualValues(t, 12, ql.Len())
+		assert.EqualValues(t, 0, ql.Remove("missing", 0, true))
+
+		// removing everything frees all the nodes
+		for i := 0; i < 12; i++ {
+			assert.EqualValues(t, 1, ql.Remove(fmt.Sprint(i), 0, i%2 == 0))
+		}
+		checkQuicklist(t, ql)
+		assert.Nil(t, ql.head)
+	})
+}
+
+func TestQuicklist_Dup(t *testing.T) {
+	withListNodeSize(4, func() {
+		ql := createNumberedQuicklist(10)
+		dup := ql.Dup()
+		checkQuicklist(t, dup)
+		assert.EqualValues(t, quicklistValues(ql), quicklistValues(dup))
+
+		dup.Replace(0, "changed")
+		dup.PushTail("new")
+		v, _ := ql.Index(0)
+		assert.EqualValues(t, "v0", v)
+		assert.EqualValues(t, 10, ql.Len())
+	})
+}
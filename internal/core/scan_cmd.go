@@ -0,0 +1,185 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scanDefaultCount is how many elements a SCAN call returns when COUNT is not given
+const scanDefaultCount = 10
+
+var errInvalidCursor = errors.New("ERR invalid cursor")
+
+// scanOptions are the options shared by SCAN and the commands scanning a single key
+type scanOptions struct {
+	pattern string // empty when every element matches
+	count   int
+	objType ObjType
+	typed   bool // only return keys of objType
+}
+
+// parseScanArgs parses the cursor and the options of a SCAN family command, TYPE is only
+// accepted by SCAN itself
+func parseScanArgs(cursorArg string, args []string, allowType bool) (uint64, *scanOptions, error) {
+	cursor, err := strconv.ParseUint(cursorArg, 10, 64)
+	if err != nil {
+		return 0, nil, errInvalidCursor
+	}
+	opts := &scanOptions{count: scanDefaultCount}
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return 0, nil, errSyntax
+		}
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			count, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return 0, nil, errNotInteger
+			}
+			if count < 1 {
+				return 0, nil, errSyntax
+			}
+			opts.count = int(min(count, int64(maxArrayLength)))
+		case "MATCH":
+			if err := validatePattern(args[i+1]); err != nil {
+				return 0, nil, err
+			}
+			opts.pattern = args[i+1]
+			if opts.pattern == "*" {
+				opts.pattern = ""
+			}
+		case "TYPE":
+			if !allowType {
+				return 0, nil, errSyntax
+			}
+			objType, ok := objTypeByName(args[i+1])
+			if !ok {
+				return 0, nil, fmt.Errorf("ERR unknown type name '%s'", args[i+1])
+			}
+			opts.objType, opts.typed = objType, true
+		default:
+			return 0, nil, errSyntax
+		}
+	}
+	return cursor, opts, nil
+}
+
+func (o *scanOptions) matches(s string) bool {
+	return o.pattern == "" || stringMatch(o.pattern, s, false)
+}
+
+// encodeScanReply encodes the next cursor and the elements found
+func encodeScanReply(cursor uint64, elements []string) []byte {
+	return Encode([]interface{}{strconv.FormatUint(cursor, 10), elements}, false)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+// A full iteration returns every key that exists from its start to its end at least once,
+// keys can be returned more than once.
+func cmdSCAN(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("SCAN")
+	}
+	cursor, opts, err := parseScanArgs(args[0], args[1:], true)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	var keys []string
+	// COUNT is a hint of the amount of work, not of the reply size: stop after visiting
+	// that many buckets worth of keys, or many empty buckets in a sparse table
+	maxIterations := opts.count * 10
+	for {
+		cursor = keyspace.Scan(cursor, func(key string, _ *Obj) {
+			keys = append(keys, key)
+		})
+		maxIterations--
+		if cursor == 0 || maxIterations == 0 || len(keys) >= opts.count {
+			break
+		}
+	}
+
+	res := keys[:0]
+	for _, key := range keys {
+		if !opts.matches(key) {
+			continue
+		}
+		// filter the expired keys out like a read would do, deleting them on the way
+		obj := lookupKey(key)
+		if obj == nil || opts.typed && obj.Type != opts.objType {
+			continue
+		}
+		res = append(res, key)
+	}
+	return encodeScanReply(cursor, res)
+}
+
+// ZSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdZSCAN(args []string) []byte {
+	return scanKeyGeneric("ZSCAN", args, ObjTypeZSet)
+}
+
+// HSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdHSCAN(args []string) []byte {
+	return scanKeyGeneric("HSCAN", args, ObjTypeHash)
+}
+
+// SSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdSSCAN(args []string) []byte {
+	return scanKeyGeneric("SSCAN", args, ObjTypeSet)
+}
+
+// scanKeyGeneric iterates the elements of the value stored at key, which must be of type
+// objType
+func scanKeyGeneric(cmdName string, args []string, objType ObjType) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	cursor, opts, err := parseScanArgs(args[1], args[2:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	obj, err := lookupKeyOfType(args[0], objType)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return encodeScanReply(0, []string{})
+	}
+
+	var res []string
+	switch v := obj.Value.(type) {
+	case *ZSet:
+		var elements []ZElement
+		elements, cursor = v.Scan(cursor, opts.count)
+		res = make([]string, 0, 2*len(elements))
+		for _, e := range elements {
+			if opts.matches(e.Ele) {
+				res = append(res, e.Ele, formatDouble(e.Score))
+			}
+		}
+	case *Hash:
+		var fields []HashField
+		fields, cursor = v.Scan(cursor, opts.count)
+		res = make([]string, 0, 2*len(fields))
+		for _, f := range fields {
+			if opts.matches(f.Field) {
+				res = append(res, f.Field, f.Value)
+			}
+		}
+	case *Set:
+		var members []string
+		members, cursor = v.Scan(cursor, opts.count)
+		res = make([]string, 0, len(members))
+		for _, member := range members {
+			if opts.matches(member) {
+				res = append(res, member)
+			}
+		}
+	default:
+		return Encode(ErrWrongType, false)
+	}
+	return encodeScanReply(cursor, res)
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"math/rand"
+	"strconv"
+
+	"memkv/internal/config"
+)
+
+// Set is an unordered collection of distinct strings. Small sets of integers are stored
+// in an Intset, the others in a Dict whose values are unused.
+type Set struct {
+	// exactly one of is and dict is set
+	is   *Intset
+	dict *Dict
+}
+
+// srandmemberShuffleRatio is how much larger than count the set must be for SRANDMEMBER
+// to pick random members one by one rather than shuffling all of them
+const srandmemberShuffleRatio = 3
+
+func NewSet() *Set {
+	return &Set{is: NewIntset()}
+}
+
+// createSetFor returns an empty set in the encoding fitting size members like first
+func createSetFor(first string, size int) *Set {
+	if _, ok := canonicalInt64(first); ok && size <= config.SetMaxIntsetEntries {
+		return NewSet()
+	}
+	return &Set{dict: CreateDict()}
+}
+
+// Encoding returns ObjEncodingIntset or ObjEncodingHashtable
+func (s *Set) Encoding() ObjEncoding {
+	if s.is != nil {
+		return ObjEncodingIntset
+	}
+	return ObjEncodingHashtable
+}
+
+// Len returns the number of members
+func (s *Set) Len() int {
+	if s.is != nil {
+		return s.is.Len()
+	}
+	return s.dict.Len()
+}
+
+// Add inserts member and reports whether it was not in the set yet
+func (s *Set) Add(member string) bool {
+	if s.is != nil {
+		v, ok := canonicalInt64(member)
+		if ok && (s.is.Len() < config.SetMaxIntsetEntries || s.is.Contains(v)) {
+			return s.is.Add(v)
+		}
+		s.convertToDict()
+	}
+	if _, ok := s.dict.Get(member); ok {
+		return false
+	}
+	s.dict.Set(member, nil)
+	return true
+}
+
+// Remove deletes member and reports whether it was in the set
+func (s *Set) Remove(member string) bool {
+	if s.is != nil {
+		v, ok := canonicalInt64(member)
+		return ok && s.is.Remove(v)
+	}
+	return s.dict.Delete(member)
+}
+
+// Contains tells whether member is in the set
+func (s *Set) Contains(member string) bool {
+	if s.is != nil {
+		v, ok := canonicalInt64(member)
+		return ok && s.is.Contains(v)
+	}
+	_, ok := s.dict.Get(member)
+	return ok
+}
+
+// ForEach calls fn for every member until it returns false. fn must not modify the set.
+func (s *Set) ForEach(fn func(member string) bool) {
+	if s.is != nil {
+		for i := 0; i < s.is.Len(); i++ {
+			if !fn(strconv.FormatInt(s.is.Get(i), 10)) {
+				return
+			}
+		}
+		return
+	}
+	s.dict.ForEach(func(member string, _ *Obj) bool {
+		return fn(member)
+	})
+}
+
+// Members returns all the members, in ascending order for an intset
+func (s *Set) Members() []string {
+	res := make([]string, 0, s.Len())
+	s.ForEach(func(member string) bool {
+		res = append(res, member)
+		return true
+	})
+	return res
+}
+
+// randomMember returns a random member of a non empty set
+func (s *Set) randomMember() string {
+	if s.is != nil {
+		return strconv.FormatInt(s.is.Get(rand.Intn(s.is.Len())), 10)
+	}
+	member, _ := s.dict.RandomKey()
+	return member
+}
+
+// RandomMembers returns count random members, possibly the same one several times with
+// repeat. Without repeat at most Len() members are returned.
+func (s *Set) RandomMembers(count int64, repeat bool) []string {
+	length := int64(s.Len())
+	if length == 0 || count <= 0 {
+		return nil
+	}
+	if repeat {
+		// count comes from the client, the reply grows as members are picked
+		res := make([]string, 0, min(count, length))
+		for i := int64(0); i < count; i++ {
+			res = append(res, s.randomMember())
+		}
+		return res
+	}
+
+	if count >= length {
+		members := s.Members()
+		rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+		return members
+	}
+	if count <= length/srandmemberShuffleRatio {
+		res := make([]string, 0, count)
+		picked := make(map[string]struct{}, count)
+		for int64(len(res)) < count {
+			member := s.randomMember()
+			if _, ok := picked[member]; ok {
+				continue
+			}
+			picked[member] = struct{}{}
+			res = append(res, member)
+		}
+		return res
+	}
+	members := s.Members()
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	return members[:count]
+}
+
+// Pop removes and returns up to count random members
+func (s *Set) Pop(count int64) []string {
+	members := s.RandomMembers(count, false)
+	for _, member := range members {
+		s.Remove(member)
+	}
+	return members
+}
+
+// Scan returns the members of about count buckets starting at cursor and the cursor of the
+// next call, 0 once every member was returned. An intset is small enough to be returned at
+// once.
+func (s *Set) Scan(cursor uint64, count int) ([]string, uint64) {
+	if s.is != nil {
+		return s.Members(), 0
+	}
+	var res []string
+	maxIterations := count * 10
+	for {
+		cursor = s.dict.Scan(cursor, func(member string, _ *Obj) {
+			res = append(res, member)
+		})
+		maxIterations--
+		if cursor == 0 || maxIterations == 0 || len(res) >= count {
+			break
+		}
+	}
+	return res, cursor
+}
+
+// Dup returns a copy of the set
+func (s *Set) Dup() *Set {
+	if s.is != nil {
+		return &Set{is: s.is.Dup()}
+	}
+	dup := &Set{dict: CreateDict()}
+	s.dict.ForEach(func(member string, _ *Obj) bool {
+		dup.dict.Set(member, nil)
+		return true
+	})
+	return dup
+}
+
+func (s *Set) convertToDict() {
+	dict := CreateDict()
+	s.ForEach(func(member string) bool {
+		dict.Set(member, nil)
+		return true
+	})
+	s.is = nil
+	s.dict = dict
+}
@@ -0,0 +1,429 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+const (
+	setOpUnion = iota
+	setOpInter
+	setOpDiff
+)
+
+// SADD key member [member ...]
+func cmdSADD(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("SADD")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil {
+		s = createSetFor(args[1], len(args)-1)
+		setKey(args[0], newSetObj(s))
+	}
+	added := 0
+	for _, member := range args[1:] {
+		if s.Add(member) {
+			added++
+		}
+	}
+	return Encode(added, false)
+}
+
+// SREM key member [member ...]
+func cmdSREM(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("SREM")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil {
+		return constants.RespZero
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if s.Remove(member) {
+			removed++
+		}
+	}
+	if s.Len() == 0 {
+		deleteKey(args[0])
+	}
+	return Encode(removed, false)
+}
+
+// SISMEMBER key member
+func cmdSISMEMBER(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("SISMEMBER")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil || !s.Contains(args[1]) {
+		return constants.RespZero
+	}
+	return constants.RespOne
+}
+
+// SMISMEMBER key member [member ...]
+func cmdSMISMEMBER(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("SMISMEMBER")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		res[i] = 0
+		if s != nil && s.Contains(member) {
+			res[i] = 1
+		}
+	}
+	return Encode(res, false)
+}
+
+// SMEMBERS key
+func cmdSMEMBERS(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("SMEMBERS")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil {
+		return constants.RespEmptyArray
+	}
+	return Encode(s.Members(), false)
+}
+
+// SCARD key
+func cmdSCARD(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("SCARD")
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil {
+		return constants.RespZero
+	}
+	return Encode(s.Len(), false)
+}
+
+// SPOP key [count]
+func cmdSPOP(args []string) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return respWrongNumberOfArgs("SPOP")
+	}
+	count := int64(-1)
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil || count < 0 {
+			return Encode(errValueOutOfRange, false)
+		}
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil {
+		if count == -1 {
+			return constants.RespNil
+		}
+		return constants.RespEmptyArray
+	}
+
+	var members []string
+	if count == -1 {
+		members = s.Pop(1)
+	} else {
+		members = s.Pop(count)
+	}
+	if s.Len() == 0 {
+		deleteKey(args[0])
+	}
+	if count == -1 {
+		return Encode(members[0], false)
+	}
+	return Encode(members, false)
+}
+
+// SRANDMEMBER key [count]
+// A positive count returns distinct members, a negative one allows repetitions and always
+// returns -count members.
+func cmdSRANDMEMBER(args []string) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return respWrongNumberOfArgs("SRANDMEMBER")
+	}
+	if len(args) == 1 {
+		s, err := lookupSet(args[0])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if s == nil {
+			return constants.RespNil
+		}
+		return Encode(s.RandomMembers(1, true)[0], false)
+	}
+
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if count < -maxRandomRepeatCount {
+		return Encode(errOutOfRange, false)
+	}
+	s, err := lookupSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s == nil || count == 0 {
+		return constants.RespEmptyArray
+	}
+	if count < 0 {
+		return Encode(s.RandomMembers(-count, true), false)
+	}
+	return Encode(s.RandomMembers(count, false), false)
+}
+
+// SMOVE source destination member
+func cmdSMOVE(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("SMOVE")
+	}
+	src, dst, member := args[0], args[1], args[2]
+	srcSet, err := lookupSet(src)
+	if err != nil {
+		return Encode(err, false)
+	}
+	dstSet, err := lookupSet(dst)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if srcSet == nil || !srcSet.Contains(member) {
+		return constants.RespZero
+	}
+	if src == dst {
+		return constants.RespOne
+	}
+
+	srcSet.Remove(member)
+	if srcSet.Len() == 0 {
+		deleteKey(src)
+	}
+	if dstSet == nil {
+		dstSet = createSetFor(member, 1)
+		setKey(dst, newSetObj(dstSet))
+	}
+	dstSet.Add(member)
+	return constants.RespOne
+}
+
+// SINTER key [key ...]
+func cmdSINTER(args []string) []byte {
+	return setOpGeneric("SINTER", args, setOpInter, false)
+}
+
+// SUNION key [key ...]
+func cmdSUNION(args []string) []byte {
+	return setOpGeneric("SUNION", args, setOpUnion, false)
+}
+
+// SDIFF key [key ...]
+func cmdSDIFF(args []string) []byte {
+	return setOpGeneric("SDIFF", args, setOpDiff, false)
+}
+
+// SINTERSTORE destination key [key ...]
+func cmdSINTERSTORE(args []string) []byte {
+	return setOpGeneric("SINTERSTORE", args, setOpInter, true)
+}
+
+// SUNIONSTORE destination key [key ...]
+func cmdSUNIONSTORE(args []string) []byte {
+	return setOpGeneric("SUNIONSTORE", args, setOpUnion, true)
+}
+
+// SDIFFSTORE destination key [key ...]
+func cmdSDIFFSTORE(args []string) []byte {
+	return setOpGeneric("SDIFFSTORE", args, setOpDiff, true)
+}
+
+// setOpGeneric implements SINTER, SUNION, SDIFF and their STORE variants, which take the
+// destination first and reply with the size of the result
+func setOpGeneric(cmdName string, args []string, op int, store bool) []byte {
+	minArgs := 1
+	if store {
+		minArgs = 2
+	}
+	if len(args) < minArgs {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	var dst string
+	keys := args
+	if store {
+		dst, keys = args[0], args[1:]
+	}
+	sets, err := lookupSets(keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	var res []string
+	switch op {
+	case setOpUnion:
+		res = setUnion(sets)
+	case setOpInter:
+		res = setInter(sets, 0)
+	case setOpDiff:
+		res = setDiff(sets)
+	}
+
+	if store {
+		return Encode(storeSetResult(dst, res), false)
+	}
+	if res == nil {
+		return constants.RespEmptyArray
+	}
+	return Encode(res, false)
+}
+
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdSINTERCARD(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("SINTERCARD")
+	}
+	numKeys, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if numKeys < 1 {
+		return Encode(errNumKeys, false)
+	}
+	if numKeys > int64(len(args)-1) {
+		return Encode(errors.New("ERR Number of keys can't be greater than number of args"), false)
+	}
+	keys := args[1 : numKeys+1]
+	var limit int64 = 0
+	rest := args[numKeys+1:]
+	if len(rest) == 2 && strings.ToUpper(rest[0]) == "LIMIT" {
+		if limit, err = strconv.ParseInt(rest[1], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if limit < 0 {
+			return Encode(errLimitNegative, false)
+		}
+	} else if len(rest) != 0 {
+		return Encode(errSyntax, false)
+	}
+
+	sets, err := lookupSets(keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(len(setInter(sets, limit)), false)
+}
+
+// lookupSets returns the sets stored at keys, nil for the keys that do not exist. It fails
+// if one of the keys holds another type.
+func lookupSets(keys []string) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		var err error
+		if sets[i], err = lookupSet(key); err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+func setUnion(sets []*Set) []string {
+	seen := make(map[string]struct{})
+	var res []string
+	for _, s := range sets {
+		if s == nil {
+			continue
+		}
+		s.ForEach(func(member string) bool {
+			if _, ok := seen[member]; !ok {
+				seen[member] = struct{}{}
+				res = append(res, member)
+			}
+			return true
+		})
+	}
+	return res
+}
+
+// setInter intersects the sets. The smallest one drives the iteration, every member is then
+// looked up in the others. A positive limit stops once that many members are found.
+func setInter(sets []*Set, limit int64) []string {
+	for _, s := range sets {
+		if s == nil {
+			return nil
+		}
+	}
+	sorted := append([]*Set(nil), sets...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Len() < sorted[b].Len() })
+
+	var res []string
+	sorted[0].ForEach(func(member string) bool {
+		for _, s := range sorted[1:] {
+			if !s.Contains(member) {
+				return true
+			}
+		}
+		res = append(res, member)
+		return limit <= 0 || int64(len(res)) < limit
+	})
+	return res
+}
+
+// setDiff returns the members of the first set that are in none of the others
+func setDiff(sets []*Set) []string {
+	if sets[0] == nil {
+		return nil
+	}
+	var res []string
+	sets[0].ForEach(func(member string) bool {
+		for _, s := range sets[1:] {
+			if s != nil && s.Contains(member) {
+				return true
+			}
+		}
+		res = append(res, member)
+		return true
+	})
+	return res
+}
+
+// storeSetResult replaces dst with a set made of members and returns its size. An empty
+// result deletes dst, empty sets do not exist in the keyspace.
+func storeSetResult(dst string, members []string) int {
+	if len(members) == 0 {
+		deleteKey(dst)
+		return 0
+	}
+	s := createSetFor(members[0], len(members))
+	for _, member := range members {
+		s.Add(member)
+	}
+	setKey(dst, newSetObj(s))
+	return s.Len()
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"memkv/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestSet(members ...string) *Set {
+	s := createSetFor(members[0], len(members))
+	for _, member := range members {
+		s.Add(member)
+	}
+	return s
+}
+
+func sortedMembers(s *Set) []string {
+	members := s.Members()
+	sort.Strings(members)
+	return members
+}
+
+func TestSet_IntsetConversion(t *testing.T) {
+	s := createTestSet("3", "1", "2")
+	assert.EqualValues(t, ObjEncodingIntset, s.Encoding())
+	assert.EqualValues(t, []string{"1", "2", "3"}, s.Members())
+	assert.True(t, s.Contains("2"))
+	// only the canonical form of an integer is in an intset
+	assert.False(t, s.Contains("02"))
+	assert.False(t, s.Remove("+2"))
+
+	s.Add("a")
+	assert.EqualValues(t, ObjEncodingHashtable, s.Encoding())
+	assert.EqualValues(t, []string{"1", "2", "3", "a"}, sortedMembers(s))
+	assert.True(t, s.Contains("2"))
+
+	s = createTestSet("1")
+	for i := 2; i <= config.SetMaxIntsetEntries; i++ {
+		s.Add(fmt.Sprint(i))
+	}
+	assert.EqualValues(t, ObjEncodingIntset, s.Encoding())
+	assert.False(t, s.Add("1"))
+	assert.EqualValues(t, ObjEncodingIntset, s.Encoding())
+	s.Add("0")
+	assert.EqualValues(t, ObjEncodingHashtable, s.Encoding())
+	assert.EqualValues(t, config.SetMaxIntsetEntries+1, s.Len())
+
+	assert.EqualValues(t, ObjEncodingHashtable, createSetFor("a", 1).Encoding())
+	assert.EqualValues(t, ObjEncodingHashtable, createSetFor("1", config.SetMaxIntsetEntries+1).Encoding())
+}
+
+func TestSet_RandomAndPop(t *testing.T) {
+	for _, s := range []*Set{createTestSet("1", "2", "3", "4", "5"), createTestSet("a", "b", "c", "d", "e")} {
+		members := s.RandomMembers(3, false)
+		assert.EqualValues(t, 3, len(members))
+		seen := make(map[string]bool)
+		for _, member := range members {
+			assert.False(t, seen[member])
+			assert.True(t, s.Contains(member))
+			seen[member] = true
+		}
+		assert.EqualValues(t, 5, len(s.RandomMembers(10, false)))
+		assert.EqualValues(t, 5, len(s.RandomMembers(math.MaxInt64/2+1, false)))
+		assert.EqualValues(t, 10, len(s.RandomMembers(10, true)))
+
+		popped := s.Pop(2)
+		assert.EqualValues(t, 3, s.Len())
+		for _, member := range popped {
+			assert.False(t, s.Contains(member))
+		}
+		assert.EqualValues(t, 3, len(s.Pop(math.MaxInt64/2+1)))
+		assert.EqualValues(t, 0, s.Len())
+		assert.Nil(t, s.Pop(1))
+	}
+}
+
+func TestSet_Algebra(t *testing.T) {
+	a := createTestSet("1", "2", "3", "4")
+	b := createTestSet("3", "4", "x")
+	c := createTestSet("4", "x", "y")
+
+	inter := setInter([]*Set{a, b, c}, 0)
+	assert.EqualValues(t, []string{"4"}, inter)
+	assert.Nil(t, setInter([]*Set{a, nil}, 0))
+	assert.EqualValues(t, 1, len(setInter([]*Set{a, b}, 1)))
+
+	union := setUnion([]*Set{a, nil, c})
+	sort.Strings(union)
+	assert.EqualValues(t, []string{"1", "2", "3", "4", "x", "y"}, union)
+
+	diff := setDiff([]*Set{a, b, nil})
+	sort.Strings(diff)
+	assert.EqualValues(t, []string{"1", "2"}, diff)
+	assert.Nil(t, setDiff([]*Set{nil, a}))
+}
+
+func TestSet_StoreResult(t *testing.T) {
+	assert.EqualValues(t, 2, storeSetResult("sstore", []string{"1", "2"}))
+	s, err := lookupSet("sstore")
+	assert.Nil(t, err)
+	assert.EqualValues(t, ObjEncodingIntset, s.Encoding())
+
+	assert.EqualValues(t, 2, storeSetResult("sstore", []string{"1", "a"}))
+	s, _ = lookupSet("sstore")
+	assert.EqualValues(t, ObjEncodingHashtable, s.Encoding())
+
+	assert.EqualValues(t, 0, storeSetResult("sstore", nil))
+	assert.Nil(t, lookupKey("sstore"))
+}
+
+func TestSet_ScanAndDup(t *testing.T) {
+	for _, s := range []*Set{createTestSet("1", "2", "3"), createNumberedSet(500)} {
+		var members []string
+		cursor := uint64(0)
+		for {
+			var batch []string
+			batch, cursor = s.Scan(cursor, 10)
+			members = append(members, batch...)
+			if cursor == 0 {
+				break
+			}
+		}
+		sort.Strings(members)
+		assert.EqualValues(t, sortedMembers(s), members)
+
+		dup := s.Dup()
+		assert.EqualValues(t, s.Encoding(), dup.Encoding())
+		dup.Remove("1")
+		dup.Add("new")
+		assert.True(t, s.Contains("1"))
+		assert.False(t, s.Contains("new"))
+	}
+}
+
+// createNumberedSet returns a hash table encoded set of the members "1", "m2"... "m<n>"
+func createNumberedSet(n int) *Set {
+	s := createTestSet("1")
+	for i := 2; i <= n; i++ {
+		s.Add(fmt.Sprintf("m%d", i))
+	}
+	return s
+}
+
+func TestSRANDMEMBER_HugeCount(t *testing.T) {
+	cmdSADD([]string{"srand", "a", "b"})
+	defer deleteKey("srand")
+	outOfRange := "-" + errOutOfRange.Error() + "\r\n"
+	assert.EqualValues(t, outOfRange, string(cmdSRANDMEMBER([]string{"srand", "-100000000000"})))
+	assert.EqualValues(t, outOfRange, string(cmdSRANDMEMBER([]string{"srand", "-9223372036854775808"})))
+	assert.EqualValues(t, "*2\r\n", string(cmdSRANDMEMBER([]string{"srand", "4611686018427387904"}))[:4])
+	assert.EqualValues(t, "*2\r\n", string(cmdSPOP([]string{"srand", "4611686018427387904"}))[:4])
+}
@@ -216,3 +216,214 @@ func (sl *Skiplist) GetRank(score float64, ele string) uint32 {
 
 	return 0
 }
+
+// GetElementByRank finds the node at rank, ranks are 1-based like the ones GetRank returns.
+// Spans let us skip whole runs of nodes, so this is O(log n) instead of a walk from the head.
+func (sl *Skiplist) GetElementByRank(rank uint32) *SkipListNode {
+	if rank == 0 {
+		return nil
+	}
+	x := sl.head
+	var traversed uint32 = 0
+
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// IsInRange tells whether some part of the list falls in the score range
+func (sl *Skiplist) IsInRange(rng *ZRangeSpec) bool {
+	if rng.isEmpty() {
+		return false
+	}
+	x := sl.tail
+	if x == nil || !rng.valueGteMin(x.score) {
+		return false
+	}
+	x = sl.head.levels[0].forward
+	if x == nil || !rng.valueLteMax(x.score) {
+		return false
+	}
+	return true
+}
+
+// FirstInRange returns the first node with a score in the range, nil if there is none
+func (sl *Skiplist) FirstInRange(rng *ZRangeSpec) *SkipListNode {
+	if !sl.IsInRange(rng) {
+		return nil
+	}
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		// go forward while the next node is still before the range
+		for x.levels[i].forward != nil && !rng.valueGteMin(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+
+	// the list is in range so the next node cannot be nil
+	x = x.levels[0].forward
+	if !rng.valueLteMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+// LastInRange returns the last node with a score in the range, nil if there is none
+func (sl *Skiplist) LastInRange(rng *ZRangeSpec) *SkipListNode {
+	if !sl.IsInRange(rng) {
+		return nil
+	}
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		// go forward while the next node is still in range
+		for x.levels[i].forward != nil && rng.valueLteMax(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+
+	if x == sl.head || !rng.valueGteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// IsInLexRange tells whether some part of the list falls in the lexicographical range.
+// Lex ranges are only meaningful when every element has the same score.
+func (sl *Skiplist) IsInLexRange(rng *ZLexRangeSpec) bool {
+	if rng.isEmpty() {
+		return false
+	}
+	x := sl.tail
+	if x == nil || !rng.valueGteMin(x.ele) {
+		return false
+	}
+	x = sl.head.levels[0].forward
+	if x == nil || !rng.valueLteMax(x.ele) {
+		return false
+	}
+	return true
+}
+
+// FirstInLexRange returns the first node in the lexicographical range, nil if there is none
+func (sl *Skiplist) FirstInLexRange(rng *ZLexRangeSpec) *SkipListNode {
+	if !sl.IsInLexRange(rng) {
+		return nil
+	}
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !rng.valueGteMin(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+	}
+
+	x = x.levels[0].forward
+	if !rng.valueLteMax(x.ele) {
+		return nil
+	}
+	return x
+}
+
+// LastInLexRange returns the last node in the lexicographical range, nil if there is none
+func (sl *Skiplist) LastInLexRange(rng *ZLexRangeSpec) *SkipListNode {
+	if !sl.IsInLexRange(rng) {
+		return nil
+	}
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && rng.valueLteMax(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+	}
+
+	if x == sl.head || !rng.valueGteMin(x.ele) {
+		return nil
+	}
+	return x
+}
+
+// DeleteRangeByScore removes every node with a score in the range and returns how many.
+// The removed nodes are consecutive, so once update points at the nodes before the first
+// one it stays valid for all of them and the spans are fixed while walking level 0 once.
+// onDelete is called with the element of each removed node.
+func (sl *Skiplist) DeleteRangeByScore(rng *ZRangeSpec, onDelete func(ele string)) uint32 {
+	update := [SkipListMaxLevel]*SkipListNode{}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !rng.valueGteMin(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	var removed uint32 = 0
+	x = x.levels[0].forward
+	for x != nil && rng.valueLteMax(x.score) {
+		next := x.levels[0].forward
+		sl.DeleteNode(x, update)
+		onDelete(x.ele)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// DeleteRangeByLex is DeleteRangeByScore for a lexicographical range
+func (sl *Skiplist) DeleteRangeByLex(rng *ZLexRangeSpec, onDelete func(ele string)) uint32 {
+	update := [SkipListMaxLevel]*SkipListNode{}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !rng.valueGteMin(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	var removed uint32 = 0
+	x = x.levels[0].forward
+	for x != nil && rng.valueLteMax(x.ele) {
+		next := x.levels[0].forward
+		sl.DeleteNode(x, update)
+		onDelete(x.ele)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// DeleteRangeByRank removes the nodes between the 1-based ranks start and end, both included
+func (sl *Skiplist) DeleteRangeByRank(start uint32, end uint32, onDelete func(ele string)) uint32 {
+	update := [SkipListMaxLevel]*SkipListNode{}
+	var traversed uint32 = 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span < start {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	var removed uint32 = 0
+	traversed++
+	x = x.levels[0].forward
+	for x != nil && traversed <= end {
+		next := x.levels[0].forward
+		sl.DeleteNode(x, update)
+		onDelete(x.ele)
+		removed++
+		traversed++
+		x = next
+	}
+	return removed
+}
@@ -3,24 +3,47 @@ package core
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
 	"memkv/internal/constants"
 )
 
+var (
+	errZAddNXXX        = errors.New("ERR XX and NX options at the same time are not compatible")
+	errZAddGTLTNX      = errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	errZAddIncrPairs   = errors.New("ERR INCR option supports a single increment-element pair")
+	errNotFloat        = errors.New("ERR value is not a valid float")
+	errResultingNaN    = errors.New("ERR resulting score is not a number (NaN)")
+	errZAddEmptyMember = errors.New("ERR member must not be empty")
+)
+
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
 func cmdZADD(args []string) []byte {
 	if len(args) < 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZADD' command"), false)
+		return respWrongNumberOfArgs("ZADD")
 	}
 	key := args[0]
 	scoreIndex := 1
 	flags := 0
+	ch := false
 	for scoreIndex < len(args) {
-		if strings.ToLower(args[scoreIndex]) == "nx" {
+		opt := strings.ToLower(args[scoreIndex])
+		if opt == "nx" {
 			flags |= ZAddInNX
-		} else if strings.ToLower(args[scoreIndex]) == "xx" {
+		} else if opt == "xx" {
 			flags |= ZAddInXX
+		} else if opt == "gt" {
+			flags |= ZAddInGT
+		} else if opt == "lt" {
+			flags |= ZAddInLT
+		} else if opt == "ch" {
+			ch = true
+		} else if opt == "incr" {
+			flags |= ZAddInIncr
 		} else {
 			break
 		}
@@ -28,48 +51,144 @@ func cmdZADD(args []string) []byte {
 	}
 	nx := (flags & ZAddInNX) != 0
 	xx := (flags & ZAddInXX) != 0
-	if nx && xx {
-		return Encode(errors.New("(error) Cannot have both NN and XX flag for 'ZADD' command"), false)
-	}
+	gt := (flags & ZAddInGT) != 0
+	lt := (flags & ZAddInLT) != 0
+	incr := (flags & ZAddInIncr) != 0
 	numScoreEleArgs := len(args) - scoreIndex
 	if numScoreEleArgs%2 == 1 || numScoreEleArgs == 0 {
-		return Encode(errors.New(fmt.Sprintf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs)), false)
+		return Encode(errSyntax, false)
 	}
-
-	zset, exist := zsetStore[key]
-	if !exist {
-		zset = CreateZSet()
-		zsetStore[key] = zset
+	if nx && xx {
+		return Encode(errZAddNXXX, false)
+	}
+	if (gt && nx) || (lt && nx) || (gt && lt) {
+		return Encode(errZAddGTLTNX, false)
+	}
+	if incr && numScoreEleArgs > 2 {
+		return Encode(errZAddIncrPairs, false)
 	}
 
-	count := 0
+	// parse every score first, a bad one must not leave the set half updated
+	scores := make([]float64, 0, numScoreEleArgs/2)
+	maxEleLen := 0
 	for i := scoreIndex; i < len(args); i += 2 {
-		ele := args[i+1]
-		score, err := strconv.ParseFloat(args[i], 64)
-		if err != nil {
-			return Encode(errors.New("(error) Score must be floating point number"), false)
+		score, ok := parseDouble(args[i])
+		if !ok {
+			return Encode(errNotFloat, false)
+		}
+		if len(args[i+1]) == 0 {
+			return Encode(errZAddEmptyMember, false)
 		}
-		ret, outFlag := zset.Add(score, ele, flags)
+		scores = append(scores, score)
+		maxEleLen = max(maxEleLen, len(args[i+1]))
+	}
+
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		if xx {
+			// nothing can be updated in a set that does not exist
+			if incr {
+				return constants.RespNil
+			}
+			return constants.RespZero
+		}
+		zset = createZSetFor(len(scores), maxEleLen)
+		setKey(key, newZSetObj(zset))
+	}
+
+	added, updated, processed := 0, 0, 0
+	var newScore float64
+	for i, score := range scores {
+		ele := args[scoreIndex+2*i+1]
+		ret, outFlag, score := zset.AddWithResult(score, ele, flags)
 		if ret != 1 {
-			return Encode(errors.New("error when adding element"), false)
+			if zset.Len() == 0 {
+				deleteKey(key)
+			}
+			return Encode(errResultingNaN, false)
+		}
+		if outFlag&ZAddOutAdded != 0 {
+			added++
+		}
+		if outFlag&ZAddOutUpdated != 0 {
+			updated++
 		}
-		if outFlag != ZAddOutNop {
-			count++
+		if outFlag&ZAddOutNop == 0 {
+			processed++
+		}
+		newScore = score
+	}
+	if zset.Len() == 0 {
+		// NX, GT or LT may have skipped every element of a new set
+		deleteKey(key)
+	} else {
+		signalKeyAsReady(key)
+	}
+
+	if incr {
+		if processed == 0 {
+			return constants.RespNil
 		}
+		return Encode(newScore, false)
+	}
+	if ch {
+		return Encode(added+updated, false)
+	}
+	return Encode(added, false)
+}
+
+// ZINCRBY key increment member
+func cmdZINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZINCRBY")
 	}
-	return Encode(count, false)
+	return cmdZADD([]string{args[0], "INCR", args[1], args[2]})
 }
 
+// ZRANK key member [WITHSCORE]
 func cmdZRANK(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
+	return zrankGeneric("ZRANK", args, false)
+}
+
+// ZREVRANK key member [WITHSCORE]
+func cmdZREVRANK(args []string) []byte {
+	return zrankGeneric("ZREVRANK", args, true)
+}
+
+// zrankGeneric implements ZRANK and ZREVRANK, reverse ranks from the highest score
+func zrankGeneric(cmdName string, args []string, reverse bool) []byte {
+	if len(args) != 2 && len(args) != 3 {
+		return respWrongNumberOfArgs(cmdName)
 	}
-	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
-		return constants.RespNil
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORE" {
+			return Encode(errSyntax, false)
+		}
+		withScore = true
+	}
+	null := constants.RespNil
+	if withScore {
+		null = constants.RespNilArray
+	}
+
+	zset, err := lookupZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return null
+	}
+	rank, score := zset.GetRank(args[1], reverse)
+	if rank < 0 {
+		return null
+	}
+	if withScore {
+		return Encode([]interface{}{rank, score}, false)
 	}
-	rank, _ := zset.GetRank(member, false)
 	return Encode(rank, false)
 }
 
@@ -78,8 +197,11 @@ func cmdZREM(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZREM' command"), false)
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constants.RespZero
 	}
 	deleted := 0
@@ -89,7 +211,7 @@ func cmdZREM(args []string) []byte {
 			deleted++
 		}
 		if zset.Len() == 0 {
-			delete(zsetStore, key)
+			deleteKey(key)
 			break
 		}
 	}
@@ -101,15 +223,40 @@ func cmdZSCORE(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCORE' command"), false)
 	}
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constants.RespNil
 	}
 	ret, score := zset.GetScore(member)
 	if ret == -1 {
 		return constants.RespNil
 	}
-	return Encode(fmt.Sprintf("%f", score), false)
+	return Encode(score, false)
+}
+
+// ZMSCORE key member [member ...]
+// Members that are not in the set, or all of them when the key does not exist, are nil.
+func cmdZMSCORE(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("ZMSCORE")
+	}
+	zset, err := lookupZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	if zset == nil {
+		return Encode(res, false)
+	}
+	for i, member := range args[1:] {
+		if ret, score := zset.GetScore(member); ret != -1 {
+			res[i] = score
+		}
+	}
+	return Encode(res, false)
 }
 
 func cmdZCARD(args []string) []byte {
@@ -117,9 +264,880 @@ func cmdZCARD(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZCARD' command"), false)
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constants.RespZero
 	}
 	return Encode(zset.Len(), false)
 }
+
+const (
+	zrangeAuto  = iota // ZRANGE and ZRANGESTORE, the range type comes from BYSCORE/BYLEX
+	zrangeRank         // start and stop are ranks
+	zrangeScore        // min and max are scores
+	zrangeLex          // min and max are lexicographical bounds
+)
+
+var (
+	errZRangeLimit      = errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	errZRangeWithScores = errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+)
+
+// ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func cmdZRANGE(args []string) []byte {
+	return zrangeGeneric("ZRANGE", args, zrangeAuto, false, false)
+}
+
+// ZRANGESTORE dst src min max [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+func cmdZRANGESTORE(args []string) []byte {
+	return zrangeGeneric("ZRANGESTORE", args, zrangeAuto, false, true)
+}
+
+// ZREVRANGE key start stop [WITHSCORES]
+func cmdZREVRANGE(args []string) []byte {
+	return zrangeGeneric("ZREVRANGE", args, zrangeRank, true, false)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRANGEBYSCORE(args []string) []byte {
+	return zrangeGeneric("ZRANGEBYSCORE", args, zrangeScore, false, false)
+}
+
+// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+func cmdZREVRANGEBYSCORE(args []string) []byte {
+	return zrangeGeneric("ZREVRANGEBYSCORE", args, zrangeScore, true, false)
+}
+
+// ZRANGEBYLEX key min max [LIMIT offset count]
+func cmdZRANGEBYLEX(args []string) []byte {
+	return zrangeGeneric("ZRANGEBYLEX", args, zrangeLex, false, false)
+}
+
+// ZREVRANGEBYLEX key max min [LIMIT offset count]
+func cmdZREVRANGEBYLEX(args []string) []byte {
+	return zrangeGeneric("ZREVRANGEBYLEX", args, zrangeLex, true, false)
+}
+
+// zrangeGeneric implements every command of the ZRANGE family. rangeType and reverse are
+// the defaults implied by the command, store is set for ZRANGESTORE whose first argument
+// is the destination key.
+func zrangeGeneric(cmdName string, args []string, rangeType int, reverse bool, store bool) []byte {
+	dst := ""
+	if store {
+		if len(args) < 1 {
+			return respWrongNumberOfArgs(cmdName)
+		}
+		dst, args = args[0], args[1:]
+	}
+	if len(args) < 3 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	key := args[0]
+
+	withScores := false
+	hasLimit := false
+	var offset, limit int64 = 0, -1
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "WITHSCORES" && !store:
+			withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			var err1, err2 error
+			offset, err1 = strconv.ParseInt(args[i+1], 10, 64)
+			limit, err2 = strconv.ParseInt(args[i+2], 10, 64)
+			if err1 != nil || err2 != nil {
+				return Encode(errNotInteger, false)
+			}
+			hasLimit = true
+			i += 2
+		case opt == "BYSCORE" && rangeType == zrangeAuto:
+			rangeType = zrangeScore
+		case opt == "BYLEX" && rangeType == zrangeAuto:
+			rangeType = zrangeLex
+		case opt == "REV" && (cmdName == "ZRANGE" || cmdName == "ZRANGESTORE"):
+			reverse = true
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if rangeType == zrangeAuto {
+		rangeType = zrangeRank
+	}
+	if hasLimit && rangeType == zrangeRank {
+		return Encode(errZRangeLimit, false)
+	}
+	if withScores && rangeType == zrangeLex {
+		return Encode(errZRangeWithScores, false)
+	}
+
+	// reversed score and lex ranges take the max bound first
+	minArg, maxArg := args[1], args[2]
+	if reverse && rangeType != zrangeRank {
+		minArg, maxArg = maxArg, minArg
+	}
+
+	var scoreRange *ZRangeSpec
+	var lexRange *ZLexRangeSpec
+	var start, end int64
+	var err error
+	switch rangeType {
+	case zrangeRank:
+		var err1, err2 error
+		start, err1 = strconv.ParseInt(minArg, 10, 64)
+		end, err2 = strconv.ParseInt(maxArg, 10, 64)
+		if err1 != nil || err2 != nil {
+			err = errNotInteger
+		}
+	case zrangeScore:
+		scoreRange, err = ParseRangeSpec(minArg, maxArg)
+	case zrangeLex:
+		lexRange, err = ParseLexRangeSpec(minArg, maxArg)
+	}
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	var res []ZElement
+	if zset != nil && offset >= 0 {
+		switch rangeType {
+		case zrangeRank:
+			start, end = normalizeRankRange(start, end, int64(zset.Len()))
+			res = zset.RangeByRank(start, end, reverse)
+		case zrangeScore:
+			res = zset.RangeByScore(scoreRange, reverse, offset, limit)
+		case zrangeLex:
+			res = zset.RangeByLex(lexRange, reverse, offset, limit)
+		}
+	}
+
+	if store {
+		return Encode(storeZSetResult(dst, res), false)
+	}
+	return encodeZElements(res, withScores)
+}
+
+// normalizeRankRange turns negative ranks, counted from the end, into positive ones
+func normalizeRankRange(start int64, end int64, length int64) (int64, int64) {
+	if start < 0 {
+		start = max(start+length, 0)
+	}
+	if end < 0 {
+		end += length
+	}
+	return start, end
+}
+
+// storeZSetResult replaces dst with a sorted set made of elements and returns its size.
+// An empty result deletes dst, empty sorted sets do not exist in the keyspace.
+func storeZSetResult(dst string, elements []ZElement) int {
+	if len(elements) == 0 {
+		deleteKey(dst)
+		return 0
+	}
+	maxEleLen := 0
+	for _, e := range elements {
+		maxEleLen = max(maxEleLen, len(e.Ele))
+	}
+	zset := createZSetFor(len(elements), maxEleLen)
+	for _, e := range elements {
+		zset.Add(e.Score, e.Ele, 0)
+	}
+	setKey(dst, newZSetObj(zset))
+	return zset.Len()
+}
+
+func encodeZElements(elements []ZElement, withScores bool) []byte {
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	res := make([]string, 0, size)
+	for _, e := range elements {
+		res = append(res, e.Ele)
+		if withScores {
+			res = append(res, formatDouble(e.Score))
+		}
+	}
+	return Encode(res, false)
+}
+
+// ZCOUNT key min max
+func cmdZCOUNT(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZCOUNT")
+	}
+	rng, err := ParseRangeSpec(args[1], args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, err := lookupZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constants.RespZero
+	}
+	return Encode(zset.CountInRange(rng), false)
+}
+
+// ZLEXCOUNT key min max
+func cmdZLEXCOUNT(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZLEXCOUNT")
+	}
+	rng, err := ParseLexRangeSpec(args[1], args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, err := lookupZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constants.RespZero
+	}
+	return Encode(zset.CountInLexRange(rng), false)
+}
+
+var (
+	errValueOutOfRange = errors.New("ERR value is out of range, must be positive")
+	errOutOfRange      = errors.New("ERR value is out of range")
+	errNumKeys         = errors.New("ERR numkeys should be greater than 0")
+	errCount           = errors.New("ERR count should be greater than 0")
+)
+
+// maxRandomRepeatCount bounds the negative counts of ZRANDMEMBER, HRANDFIELD and
+// SRANDMEMBER. Their reply is built in memory before it is sent and, unlike a distinct
+// pick, is not limited by the size of the collection.
+const maxRandomRepeatCount = 1 << 24
+
+// ZPOPMIN key [count]
+func cmdZPOPMIN(args []string) []byte {
+	return zpopGeneric("ZPOPMIN", args, false)
+}
+
+// ZPOPMAX key [count]
+func cmdZPOPMAX(args []string) []byte {
+	return zpopGeneric("ZPOPMAX", args, true)
+}
+
+func zpopGeneric(cmdName string, args []string, fromMax bool) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	var count int64 = 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if count < 0 {
+			return Encode(errValueOutOfRange, false)
+		}
+	}
+	elements, err := zpopFromKey(args[0], count, fromMax)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return encodeZElements(elements, true)
+}
+
+// zpopFromKey pops up to count elements from the sorted set at key, deleting the key once empty
+func zpopFromKey(key string, count int64, fromMax bool) ([]ZElement, error) {
+	zset, err := lookupZSet(key)
+	if zset == nil {
+		return nil, err
+	}
+	elements := zset.Pop(count, fromMax)
+	if zset.Len() == 0 {
+		deleteKey(key)
+	}
+	return elements, nil
+}
+
+// parseMPopArgs parses "numkeys key [key ...] where [COUNT count]" for ZMPOP and LMPOP, where
+// being one of the two sides. second tells whether it is the second one, MAX or RIGHT.
+func parseMPopArgs(args []string, sides [2]string) (keys []string, second bool, count int64, err error) {
+	numKeys, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, false, 0, errNotInteger
+	}
+	if numKeys <= 0 {
+		return nil, false, 0, errNumKeys
+	}
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, errSyntax
+	}
+	keys = args[1 : numKeys+1]
+	switch strings.ToUpper(args[numKeys+1]) {
+	case sides[0]:
+	case sides[1]:
+		second = true
+	default:
+		return nil, false, 0, errSyntax
+	}
+	count = 1
+	rest := args[numKeys+2:]
+	if len(rest) == 2 && strings.ToUpper(rest[0]) == "COUNT" {
+		count, err = strconv.ParseInt(rest[1], 10, 64)
+		if err != nil || count <= 0 {
+			return nil, false, 0, errCount
+		}
+	} else if len(rest) != 0 {
+		return nil, false, 0, errSyntax
+	}
+	return keys, second, count, nil
+}
+
+var zmpopSides = [2]string{"MIN", "MAX"}
+
+// encodeZMPop builds the reply of ZMPOP and BZMPOP: the key and its popped elements
+func encodeZMPop(key string, elements []ZElement) []byte {
+	pairs := make([]interface{}, len(elements))
+	for i, e := range elements {
+		pairs[i] = []string{e.Ele, formatDouble(e.Score)}
+	}
+	return Encode([]interface{}{key, pairs}, false)
+}
+
+// ZMPOP numkeys key [key ...] MIN | MAX [COUNT count]
+func cmdZMPOP(args []string) []byte {
+	if len(args) < 3 {
+		return respWrongNumberOfArgs("ZMPOP")
+	}
+	keys, fromMax, count, err := parseMPopArgs(args, zmpopSides)
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, key := range keys {
+		elements, err := zpopFromKey(key, count, fromMax)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if len(elements) > 0 {
+			return encodeZMPop(key, elements)
+		}
+	}
+	return constants.RespNilArray
+}
+
+// BZPOPMIN key [key ...] timeout
+func cmdBZPOPMIN(args []string, c io.ReadWriter) []byte {
+	return bzpopGeneric("BZPOPMIN", args, false, c)
+}
+
+// BZPOPMAX key [key ...] timeout
+func cmdBZPOPMAX(args []string, c io.ReadWriter) []byte {
+	return bzpopGeneric("BZPOPMAX", args, true, c)
+}
+
+func bzpopGeneric(cmdName string, args []string, fromMax bool, c io.ReadWriter) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	deadline, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys := args[:len(args)-1]
+	if err := checkZSetKeys(keys); err != nil {
+		return Encode(err, false)
+	}
+	return serveOrBlock(c, keys, deadline, constants.RespNilArray, func(key string) []byte {
+		elements, err := zpopFromKey(key, 1, fromMax)
+		if err != nil || len(elements) == 0 {
+			return nil
+		}
+		return Encode([]string{key, elements[0].Ele, formatDouble(elements[0].Score)}, false)
+	})
+}
+
+// BZMPOP timeout numkeys key [key ...] MIN | MAX [COUNT count]
+func cmdBZMPOP(args []string, c io.ReadWriter) []byte {
+	if len(args) < 4 {
+		return respWrongNumberOfArgs("BZMPOP")
+	}
+	deadline, err := parseTimeout(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys, fromMax, count, err := parseMPopArgs(args[1:], zmpopSides)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if err := checkZSetKeys(keys); err != nil {
+		return Encode(err, false)
+	}
+	return serveOrBlock(c, keys, deadline, constants.RespNilArray, func(key string) []byte {
+		elements, err := zpopFromKey(key, count, fromMax)
+		if err != nil || len(elements) == 0 {
+			return nil
+		}
+		return encodeZMPop(key, elements)
+	})
+}
+
+// checkZSetKeys fails with ErrWrongType when one of the keys holds something else than a
+// sorted set, blocking on such a key could never be served
+func checkZSetKeys(keys []string) error {
+	for _, key := range keys {
+		if _, err := lookupZSet(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	zsetOpUnion = iota
+	zsetOpInter
+	zsetOpDiff
+)
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+var (
+	errWeightNotFloat = errors.New("ERR weight value is not a float")
+	errLimitNegative  = errors.New("ERR LIMIT can't be negative")
+)
+
+// zsetOpSource is an input of the sorted set algebra commands
+type zsetOpSource interface {
+	Len() int
+	ForEach(fn func(ele string, score float64) bool)
+	Score(ele string) (float64, bool)
+}
+
+type zsetSource struct {
+	zs *ZSet
+}
+
+func (s zsetSource) Len() int {
+	return s.zs.Len()
+}
+
+func (s zsetSource) ForEach(fn func(ele string, score float64) bool) {
+	s.zs.ForEach(fn)
+}
+
+func (s zsetSource) Score(ele string) (float64, bool) {
+	ret, score := s.zs.GetScore(ele)
+	return score, ret == 0
+}
+
+// setSource lets a set be an input of the sorted set algebra, every member has a score of 1
+type setSource struct {
+	s *Set
+}
+
+func (s setSource) Len() int {
+	return s.s.Len()
+}
+
+func (s setSource) ForEach(fn func(ele string, score float64) bool) {
+	s.s.ForEach(func(member string) bool {
+		return fn(member, 1)
+	})
+}
+
+func (s setSource) Score(ele string) (float64, bool) {
+	return 1, s.s.Contains(ele)
+}
+
+// lookupZSetOpSource returns the value at key as an input of ZUNION, ZINTER and ZDIFF,
+// nil when the key does not exist
+func lookupZSetOpSource(key string) (zsetOpSource, error) {
+	obj := lookupKey(key)
+	if obj == nil {
+		return nil, nil
+	}
+	switch obj.Type {
+	case ObjTypeZSet:
+		return zsetSource{zs: obj.Value.(*ZSet)}, nil
+	case ObjTypeSet:
+		return setSource{s: obj.Value.(*Set)}, nil
+	}
+	return nil, ErrWrongType
+}
+
+// ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+func cmdZUNION(args []string) []byte {
+	return zsetOpGeneric("ZUNION", args, zsetOpUnion, false)
+}
+
+// ZINTER numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+func cmdZINTER(args []string) []byte {
+	return zsetOpGeneric("ZINTER", args, zsetOpInter, false)
+}
+
+// ZDIFF numkeys key [key ...] [WITHSCORES]
+func cmdZDIFF(args []string) []byte {
+	return zsetOpGeneric("ZDIFF", args, zsetOpDiff, false)
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+func cmdZUNIONSTORE(args []string) []byte {
+	return zsetOpGeneric("ZUNIONSTORE", args, zsetOpUnion, true)
+}
+
+// ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+func cmdZINTERSTORE(args []string) []byte {
+	return zsetOpGeneric("ZINTERSTORE", args, zsetOpInter, true)
+}
+
+// ZDIFFSTORE destination numkeys key [key ...]
+func cmdZDIFFSTORE(args []string) []byte {
+	return zsetOpGeneric("ZDIFFSTORE", args, zsetOpDiff, true)
+}
+
+// zsetOpGeneric implements ZUNION, ZINTER, ZDIFF and their STORE variants
+func zsetOpGeneric(cmdName string, args []string, op int, store bool) []byte {
+	dst := ""
+	if store {
+		if len(args) < 1 {
+			return respWrongNumberOfArgs(cmdName)
+		}
+		dst, args = args[0], args[1:]
+	}
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(cmdName)
+	}
+	numKeys, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if numKeys < 1 {
+		return Encode(fmt.Errorf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(cmdName)), false)
+	}
+	if numKeys > int64(len(args)-1) {
+		return Encode(errSyntax, false)
+	}
+	keys := args[1 : numKeys+1]
+
+	weights := make([]float64, len(keys))
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := aggregateSum
+	withScores := false
+	for i := int(numKeys) + 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "WEIGHTS" && op != zsetOpDiff && i+len(keys) < len(args):
+			for j := range weights {
+				var ok bool
+				if weights[j], ok = parseDouble(args[i+1+j]); !ok {
+					return Encode(errWeightNotFloat, false)
+				}
+			}
+			i += len(keys)
+		case opt == "AGGREGATE" && op != zsetOpDiff && i+1 < len(args):
+			switch strings.ToUpper(args[i+1]) {
+			case "SUM":
+				aggregate = aggregateSum
+			case "MIN":
+				aggregate = aggregateMin
+			case "MAX":
+				aggregate = aggregateMax
+			default:
+				return Encode(errSyntax, false)
+			}
+			i++
+		case opt == "WITHSCORES" && !store:
+			withScores = true
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	sources := make([]zsetOpSource, len(keys))
+	for i, key := range keys {
+		if sources[i], err = lookupZSetOpSource(key); err != nil {
+			return Encode(err, false)
+		}
+	}
+
+	var res []ZElement
+	switch op {
+	case zsetOpUnion:
+		res = zsetUnion(sources, weights, aggregate)
+	case zsetOpInter:
+		res = zsetInter(sources, weights, aggregate, 0)
+	case zsetOpDiff:
+		res = zsetDiff(sources)
+	}
+
+	if store {
+		return Encode(storeZSetResult(dst, res), false)
+	}
+	sortZElements(res)
+	return encodeZElements(res, withScores)
+}
+
+// ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdZINTERCARD(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs("ZINTERCARD")
+	}
+	numKeys, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if numKeys < 1 {
+		return Encode(errNumKeys, false)
+	}
+	if numKeys > int64(len(args)-1) {
+		return Encode(errors.New("ERR Number of keys can't be greater than number of args"), false)
+	}
+	keys := args[1 : numKeys+1]
+	var limit int64 = 0
+	rest := args[numKeys+1:]
+	if len(rest) == 2 && strings.ToUpper(rest[0]) == "LIMIT" {
+		if limit, err = strconv.ParseInt(rest[1], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if limit < 0 {
+			return Encode(errLimitNegative, false)
+		}
+	} else if len(rest) != 0 {
+		return Encode(errSyntax, false)
+	}
+
+	sources := make([]zsetOpSource, len(keys))
+	for i, key := range keys {
+		if sources[i], err = lookupZSetOpSource(key); err != nil {
+			return Encode(err, false)
+		}
+	}
+	weights := make([]float64, len(keys))
+	return Encode(len(zsetInter(sources, weights, aggregateSum, limit)), false)
+}
+
+// weightedScore applies a weight, 0 * inf is 0 rather than NaN like in Redis
+func weightedScore(score float64, weight float64) float64 {
+	res := score * weight
+	if math.IsNaN(res) {
+		return 0
+	}
+	return res
+}
+
+func aggregateScores(target float64, value float64, aggregate int) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return min(target, value)
+	case aggregateMax:
+		return max(target, value)
+	}
+	res := target + value
+	if math.IsNaN(res) {
+		// +inf + -inf
+		return 0
+	}
+	return res
+}
+
+func zsetUnion(sources []zsetOpSource, weights []float64, aggregate int) []ZElement {
+	scores := make(map[string]float64)
+	for i, src := range sources {
+		if src == nil {
+			continue
+		}
+		src.ForEach(func(ele string, score float64) bool {
+			score = weightedScore(score, weights[i])
+			if cur, ok := scores[ele]; ok {
+				score = aggregateScores(cur, score, aggregate)
+			}
+			scores[ele] = score
+			return true
+		})
+	}
+	res := make([]ZElement, 0, len(scores))
+	for ele, score := range scores {
+		res = append(res, ZElement{Ele: ele, Score: score})
+	}
+	return res
+}
+
+// zsetInter intersects the sources. The smallest one drives the iteration, every element is
+// then looked up in the others. A positive limit stops once that many elements are found.
+func zsetInter(sources []zsetOpSource, weights []float64, aggregate int, limit int64) []ZElement {
+	order := make([]int, len(sources))
+	for i, src := range sources {
+		if src == nil || src.Len() == 0 {
+			return nil
+		}
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return sources[order[a]].Len() < sources[order[b]].Len()
+	})
+
+	var res []ZElement
+	smallest := order[0]
+	sources[smallest].ForEach(func(ele string, score float64) bool {
+		score = weightedScore(score, weights[smallest])
+		for _, i := range order[1:] {
+			other, ok := sources[i].Score(ele)
+			if !ok {
+				return true
+			}
+			score = aggregateScores(score, weightedScore(other, weights[i]), aggregate)
+		}
+		res = append(res, ZElement{Ele: ele, Score: score})
+		return limit <= 0 || int64(len(res)) < limit
+	})
+	return res
+}
+
+// zsetDiff returns the elements of the first source that are in none of the others
+func zsetDiff(sources []zsetOpSource) []ZElement {
+	if sources[0] == nil {
+		return nil
+	}
+	var res []ZElement
+	sources[0].ForEach(func(ele string, score float64) bool {
+		for _, src := range sources[1:] {
+			if src == nil {
+				continue
+			}
+			if _, ok := src.Score(ele); ok {
+				return true
+			}
+		}
+		res = append(res, ZElement{Ele: ele, Score: score})
+		return true
+	})
+	return res
+}
+
+// sortZElements orders elements the way a sorted set does, by score then member
+func sortZElements(elements []ZElement) {
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].Score == elements[j].Score {
+			return elements[i].Ele < elements[j].Ele
+		}
+		return elements[i].Score < elements[j].Score
+	})
+}
+
+// ZREMRANGEBYRANK key start stop
+func cmdZREMRANGEBYRANK(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZREMRANGEBYRANK")
+	}
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	return zremrangeGeneric(args[0], func(zset *ZSet) int64 {
+		start, end := normalizeRankRange(start, end, int64(zset.Len()))
+		return zset.DeleteRangeByRank(start, end)
+	})
+}
+
+// ZREMRANGEBYSCORE key min max
+func cmdZREMRANGEBYSCORE(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZREMRANGEBYSCORE")
+	}
+	rng, err := ParseRangeSpec(args[1], args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return zremrangeGeneric(args[0], func(zset *ZSet) int64 {
+		return zset.DeleteRangeByScore(rng)
+	})
+}
+
+// ZREMRANGEBYLEX key min max
+func cmdZREMRANGEBYLEX(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("ZREMRANGEBYLEX")
+	}
+	rng, err := ParseLexRangeSpec(args[1], args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return zremrangeGeneric(args[0], func(zset *ZSet) int64 {
+		return zset.DeleteRangeByLex(rng)
+	})
+}
+
+// zremrangeGeneric runs remove on the sorted set at key and deletes the key once it is empty
+func zremrangeGeneric(key string, remove func(zset *ZSet) int64) []byte {
+	zset, err := lookupZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constants.RespZero
+	}
+	removed := remove(zset)
+	if zset.Len() == 0 {
+		deleteKey(key)
+	}
+	return Encode(removed, false)
+}
+
+// ZRANDMEMBER key [count [WITHSCORES]]
+// A positive count returns distinct elements, a negative one allows repetitions and always
+// returns -count elements.
+func cmdZRANDMEMBER(args []string) []byte {
+	if len(args) < 1 || len(args) > 3 {
+		return respWrongNumberOfArgs("ZRANDMEMBER")
+	}
+	if len(args) == 1 {
+		zset, err := lookupZSet(args[0])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if zset == nil {
+			return constants.RespNil
+		}
+		return Encode(zset.RandomElements(1, true)[0].Ele, false)
+	}
+
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	withScores := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORES" {
+			return Encode(errSyntax, false)
+		}
+		withScores = true
+	}
+	// the reply holds up to 2*|count| items, refuse counts that cannot be represented
+	if count < -maxRandomRepeatCount || count > math.MaxInt64/2 {
+		return Encode(errOutOfRange, false)
+	}
+
+	zset, err := lookupZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil || count == 0 {
+		return constants.RespEmptyArray
+	}
+	if count < 0 {
+		return encodeZElements(zset.RandomElements(-count, true), withScores)
+	}
+	return encodeZElements(zset.RandomElements(count, false), withScores)
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZMPOP_NumKeysOutOfRange(t *testing.T) {
+	syntaxErr := "-" + errSyntax.Error() + "\r\n"
+	for _, numKeys := range []string{"2", "9223372036854775807"} {
+		assert.EqualValues(t, syntaxErr, string(cmdZMPOP([]string{numKeys, "z", "MIN"})))
+		assert.EqualValues(t, syntaxErr, string(cmdLMPOP([]string{numKeys, "l", "LEFT"})))
+	}
+}
+
+func TestZSetAlgebra_Commands(t *testing.T) {
+	cmdZADD([]string{"za", "1", "a", "2", "b", "3", "c"})
+	cmdZADD([]string{"zb", "10", "b", "20", "c", "30", "d"})
+	cmdSADD([]string{"sc", "c", "d"})
+	defer cmdDEL([]string{"za", "zb", "sc", "zdst"})
+
+	tests := []struct {
+		name string
+		cmd  func([]string) []byte
+		args []string
+		want string
+	}{
+		{"union", cmdZUNION, []string{"2", "za", "zb", "WITHSCORES"},
+			"*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$2\r\n12\r\n$1\r\nc\r\n$2\r\n23\r\n$1\r\nd\r\n$2\r\n30\r\n"},
+		{"union weights", cmdZUNION, []string{"2", "za", "zb", "WEIGHTS", "2", "0.5", "WITHSCORES"},
+			"*8\r\n$1\r\na\r\n$1\r\n2\r\n$1\r\nb\r\n$1\r\n9\r\n$1\r\nd\r\n$2\r\n15\r\n$1\r\nc\r\n$2\r\n16\r\n"},
+		{"union aggregate max", cmdZUNION, []string{"2", "za", "zb", "AGGREGATE", "MAX", "WITHSCORES"},
+			"*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$2\r\n10\r\n$1\r\nc\r\n$2\r\n20\r\n$1\r\nd\r\n$2\r\n30\r\n"},
+		{"union missing key", cmdZUNION, []string{"2", "za", "missing"},
+			"*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"inter aggregate min", cmdZINTER, []string{"2", "za", "zb", "AGGREGATE", "MIN", "WITHSCORES"},
+			"*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"inter with a set", cmdZINTER, []string{"3", "za", "zb", "sc", "WITHSCORES"},
+			"*2\r\n$1\r\nc\r\n$2\r\n24\r\n"},
+		{"inter missing key", cmdZINTER, []string{"2", "za", "missing"}, "*0\r\n"},
+		{"diff", cmdZDIFF, []string{"2", "za", "zb", "WITHSCORES"},
+			"*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"diff missing key", cmdZDIFF, []string{"2", "za", "missing"},
+			"*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"diff missing first key", cmdZDIFF, []string{"2", "missing", "za"}, "*0\r\n"},
+		{"intercard", cmdZINTERCARD, []string{"2", "za", "zb"}, ":2\r\n"},
+		{"intercard limit", cmdZINTERCARD, []string{"2", "za", "zb", "LIMIT", "1"}, ":1\r\n"},
+		{"intercard limit 0", cmdZINTERCARD, []string{"2", "za", "zb", "LIMIT", "0"}, ":2\r\n"},
+		{"intercard negative limit", cmdZINTERCARD, []string{"2", "za", "zb", "LIMIT", "-1"},
+			"-" + errLimitNegative.Error() + "\r\n"},
+		{"weights count mismatch", cmdZUNION, []string{"2", "za", "zb", "WEIGHTS", "1"},
+			"-" + errSyntax.Error() + "\r\n"},
+		{"unionstore", cmdZUNIONSTORE, []string{"zdst", "2", "za", "zb", "WEIGHTS", "1", "2"}, ":4\r\n"},
+		{"interstore", cmdZINTERSTORE, []string{"zdst", "2", "za", "zb"}, ":2\r\n"},
+		{"diffstore", cmdZDIFFSTORE, []string{"zdst", "2", "za", "zb"}, ":1\r\n"},
+	}
+	for _, tc := range tests {
+		assert.EqualValues(t, tc.want, string(tc.cmd(tc.args)), tc.name)
+	}
+
+	// an empty result deletes the destination
+	assert.EqualValues(t, ":0\r\n", string(cmdZINTERSTORE([]string{"zdst", "2", "za", "missing"})))
+	assert.Nil(t, lookupKey("zdst"))
+	cmdZUNIONSTORE([]string{"zdst", "2", "za", "zb", "AGGREGATE", "SUM", "WEIGHTS", "1", "2"})
+	assert.EqualValues(t, "$2\r\n22\r\n", string(cmdZSCORE([]string{"zdst", "b"})))
+}
+
+// countingSource counts the elements a sorted set algebra command goes through
+type countingSource struct {
+	zsetOpSource
+	visited int
+}
+
+func (s *countingSource) ForEach(fn func(ele string, score float64) bool) {
+	s.zsetOpSource.ForEach(func(ele string, score float64) bool {
+		s.visited++
+		return fn(ele, score)
+	})
+}
+
+func TestZSetAlgebra_InterLimitStops(t *testing.T) {
+	zs := createNumberedZSet(100)
+	small := &countingSource{zsetOpSource: zsetSource{zs}}
+	res := zsetInter([]zsetOpSource{small, zsetSource{createNumberedZSet(200)}}, []float64{1, 1}, aggregateSum, 3)
+	assert.EqualValues(t, 3, len(res))
+	assert.EqualValues(t, 3, small.visited)
+}
@@ -1,16 +1,158 @@
 package core
 
-var zsetStore map[string]*ZSet
+import (
+	"errors"
+	"time"
 
-// var setStore map[string]Set
-// var dictStore *Dict
-// var sbStore map[string]*SBChain
-// var cmsStore map[string]*CMS
+	"memkv/internal/constants"
+)
+
+// keyspace holds every key regardless of the type of its value
+var keyspace *Dict
+
+// expires maps the keys that have a time to live to their expiration time, unix time in milliseconds
+var expires map[string]int64
+
+// hashesWithFieldExpires are the keys of the hashes having fields with a time to live, the
+// active expire cycle of hash fields goes through them
+var hashesWithFieldExpires map[string]struct{}
+
+var ErrWrongType = errors.New(constants.ResponseWrongType)
 
 func init() {
-	zsetStore = make(map[string]*ZSet)
-	// setStore = make(map[string]Set)
-	// dictStore = CreateDict()
-	// sbStore = make(map[string]*SBChain)
-	// cmsStore = make(map[string]*CMS)
+	keyspace = CreateDict()
+	expires = make(map[string]int64)
+	hashesWithFieldExpires = make(map[string]struct{})
+}
+
+// ActiveRehash spends up to timeLimit moving the keyspace to its resized hash table, a
+// keyspace that stops being accessed would otherwise keep both tables allocated
+func ActiveRehash(timeLimit time.Duration) {
+	keyspace.RehashFor(timeLimit)
+}
+
+func currentTimeMs() int64 {
+	return time.Now().UnixMilli()
+}
+
+// lookupKey returns the object stored at key, or nil if the key does not exist.
+// A key whose time to live has elapsed is deleted here, on access, as are the expired
+// fields of a hash, and the hash itself once none is left.
+func lookupKey(key string) *Obj {
+	if expireIfNeeded(key) {
+		return nil
+	}
+	obj, ok := keyspace.Get(key)
+	if !ok {
+		return nil
+	}
+	if h, ok := obj.Value.(*Hash); ok && h.expireFields(currentTimeMs()) > 0 && h.Len() == 0 {
+		deleteKey(key)
+		return nil
+	}
+	obj.lastAccessedAt = currentTimeMs()
+	return obj
+}
+
+// lookupKeyOfType is lookupKey that fails with ErrWrongType when the value is not of objType
+func lookupKeyOfType(key string, objType ObjType) (*Obj, error) {
+	obj := lookupKey(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.Type != objType {
+		return nil, ErrWrongType
+	}
+	return obj, nil
+}
+
+// lookupList returns the list stored at key, nil if the key does not exist
+func lookupList(key string) (*Quicklist, error) {
+	obj, err := lookupKeyOfType(key, ObjTypeList)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*Quicklist), nil
+}
+
+// lookupHash returns the hash stored at key, nil if the key does not exist
+func lookupHash(key string) (*Hash, error) {
+	obj, err := lookupKeyOfType(key, ObjTypeHash)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*Hash), nil
+}
+
+// lookupSet returns the set stored at key, nil if the key does not exist
+func lookupSet(key string) (*Set, error) {
+	obj, err := lookupKeyOfType(key, ObjTypeSet)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*Set), nil
+}
+
+// lookupZSet returns the sorted set stored at key, nil if the key does not exist
+func lookupZSet(key string) (*ZSet, error) {
+	obj, err := lookupKeyOfType(key, ObjTypeZSet)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*ZSet), nil
+}
+
+// setKey stores obj at key, overwriting any existing value. Like a plain Redis SET, the
+// new value does not inherit the time to live of the old one.
+func setKey(key string, obj *Obj) {
+	keyspace.Set(key, obj)
+	delete(expires, key)
+	delete(hashesWithFieldExpires, key)
+	if h, ok := obj.Value.(*Hash); ok && h.hasFieldExpires() {
+		// a renamed or copied hash
+		trackHashFieldExpires(key)
+	}
+	signalKeyAsReady(key)
+}
+
+// deleteKey removes key from the keyspace and reports whether it existed
+func deleteKey(key string) bool {
+	delete(expires, key)
+	delete(hashesWithFieldExpires, key)
+	return keyspace.Delete(key)
+}
+
+// trackHashFieldExpires records that the hash at key has fields with a time to live
+func trackHashFieldExpires(key string) {
+	hashesWithFieldExpires[key] = struct{}{}
+}
+
+// setExpire sets the expiration time of an existing key, unix time in milliseconds
+func setExpire(key string, at int64) {
+	expires[key] = at
+}
+
+// getExpire returns the expiration time of key, false if it has none
+func getExpire(key string) (int64, bool) {
+	at, ok := expires[key]
+	return at, ok
+}
+
+// removeExpire makes key persistent and reports whether it had a time to live
+func removeExpire(key string) bool {
+	if _, ok := expires[key]; !ok {
+		return false
+	}
+	delete(expires, key)
+	return true
+}
+
+// expireIfNeeded deletes key if its time to live has elapsed and reports whether it did
+func expireIfNeeded(key string) bool {
+	at, ok := expires[key]
+	if !ok || at > currentTimeMs() {
+		return false
+	}
+	deleteKey(key)
+	return true
 }
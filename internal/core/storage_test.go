@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupKeyOfType_WrongType(t *testing.T) {
+	creators := map[ObjType]func(key string){
+		ObjTypeString: func(key string) { cmdSET([]string{key, "v"}) },
+		ObjTypeList:   func(key string) { cmdRPUSH([]string{key, "v"}) },
+		ObjTypeHash:   func(key string) { cmdHSET([]string{key, "f", "v"}) },
+		ObjTypeSet:    func(key string) { cmdSADD([]string{key, "v"}) },
+		ObjTypeZSet:   func(key string) { cmdZADD([]string{key, "1", "v"}) },
+	}
+	// a read and a write command of each type
+	commands := map[ObjType][]func(key string) []byte{
+		ObjTypeString: {
+			func(key string) []byte { return cmdGET([]string{key}) },
+			func(key string) []byte { return cmdSET([]string{key, "x", "GET"}) },
+		},
+		ObjTypeList: {
+			func(key string) []byte { return cmdLLEN([]string{key}) },
+			func(key string) []byte { return cmdLPUSH([]string{key, "x"}) },
+		},
+		ObjTypeHash: {
+			func(key string) []byte { return cmdHGET([]string{key, "f"}) },
+			func(key string) []byte { return cmdHSET([]string{key, "f", "x"}) },
+		},
+		ObjTypeSet: {
+			func(key string) []byte { return cmdSISMEMBER([]string{key, "v"}) },
+			func(key string) []byte { return cmdSADD([]string{key, "x"}) },
+		},
+		ObjTypeZSet: {
+			func(key string) []byte { return cmdZSCORE([]string{key, "v"}) },
+			func(key string) []byte { return cmdZADD([]string{key, "2", "x"}) },
+		},
+	}
+	wrongType := "-" + ErrWrongType.Error() + "\r\n"
+
+	for keyType, create := range creators {
+		for cmdType, cmds := range commands {
+			for i, cmd := range cmds {
+				deleteKey("wt")
+				create("wt")
+				res := string(cmd("wt"))
+				if cmdType == keyType {
+					assert.NotEqualValues(t, wrongType, res, "%s command %d on a %s", cmdType, i, keyType)
+				} else {
+					assert.EqualValues(t, wrongType, res, "%s command %d on a %s", cmdType, i, keyType)
+					// the key is left untouched
+					assert.EqualValues(t, keyType, lookupKey("wt").Type)
+				}
+			}
+		}
+	}
+	deleteKey("wt")
+
+	obj, err := lookupKeyOfType("wt", ObjTypeString)
+	assert.Nil(t, obj)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"memkv/internal/constants"
+)
+
+const (
+	setInNX      = 1 << 0 // Only set the key if it does not already exist
+	setInXX      = 1 << 1 // Only set the key if it already exists
+	setInGet     = 1 << 2 // Return the old value
+	setInKeepTTL = 1 << 3 // Retain the time to live of the key
+	setInExpire  = 1 << 4 // One of EX, PX, EXAT, PXAT was given
+	getExPersist = 1 << 5 // GETEX only, remove the time to live
+)
+
+var (
+	errSyntax     = errors.New(constants.ResponseSyntaxError)
+	errNotInteger = errors.New(constants.ResponseNotInteger)
+)
+
+// lookupString returns the string stored at key, the bool is false if the key does not exist
+func lookupString(key string) (string, bool, error) {
+	obj, err := lookupKeyOfType(key, ObjTypeString)
+	if obj == nil {
+		return "", false, err
+	}
+	return obj.stringValue(), true, nil
+}
+
+// parseExpireTime converts the argument of EX, PX, EXAT or PXAT to an absolute unix time in milliseconds
+func parseExpireTime(unit string, arg string, cmdName string) (int64, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(cmdName))
+	if n <= 0 {
+		return 0, errInvalid
+	}
+	switch unit {
+	case "EX", "EXAT":
+		if n > math.MaxInt64/1000 {
+			return 0, errInvalid
+		}
+		n *= 1000
+	}
+	switch unit {
+	case "EX", "PX":
+		if n > math.MaxInt64-currentTimeMs() {
+			return 0, errInvalid
+		}
+		n += currentTimeMs()
+	}
+	return n, nil
+}
+
+// parseSetOptions parses the options of SET and GETEX. Options not in allowed are syntax errors.
+func parseSetOptions(args []string, allowed int, cmdName string) (flags int, expireAt int64, err error) {
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "NX" && allowed&setInNX != 0 && flags&setInXX == 0:
+			flags |= setInNX
+		case opt == "XX" && allowed&setInXX != 0 && flags&setInNX == 0:
+			flags |= setInXX
+		case opt == "GET" && allowed&setInGet != 0:
+			flags |= setInGet
+		case opt == "KEEPTTL" && allowed&setInKeepTTL != 0 && flags&(setInExpire|getExPersist) == 0:
+			flags |= setInKeepTTL
+		case opt == "PERSIST" && allowed&getExPersist != 0 && flags&(setInExpire|setInKeepTTL) == 0:
+			flags |= getExPersist
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") &&
+			flags&(setInExpire|setInKeepTTL|getExPersist) == 0 && i+1 < len(args):
+			expireAt, err = parseExpireTime(opt, args[i+1], cmdName)
+			if err != nil {
+				return 0, 0, err
+			}
+			flags |= setInExpire
+			i++
+		default:
+			return 0, 0, errSyntax
+		}
+	}
+	return flags, expireAt, nil
+}
+
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func cmdSET(args []string) []byte {
+	if len(args) < 2 {
+		return respWrongNumberOfArgs(CommandSet)
+	}
+	key, value := args[0], args[1]
+	flags, expireAt, err := parseSetOptions(args[2:], setInNX|setInXX|setInGet|setInKeepTTL|setInExpire, CommandSet)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	old, exists, err := lookupString(key)
+	if err != nil && flags&setInGet != 0 {
+		// SET ... GET must not overwrite a value it cannot return
+		return Encode(err, false)
+	}
+	exists = exists || err != nil
+
+	var res []byte = constants.RespOk
+	if flags&setInGet != 0 {
+		res = constants.RespNil
+		if exists {
+			res = Encode(old, false)
+		}
+	}
+
+	if (flags&setInNX != 0 && exists) || (flags&setInXX != 0 && !exists) {
+		if flags&setInGet != 0 {
+			return res
+		}
+		return constants.RespNil
+	}
+
+	keepExpire, hasExpire := getExpire(key)
+	setKey(key, newStringObj(value))
+	if flags&setInExpire != 0 {
+		setExpire(key, expireAt)
+	} else if flags&setInKeepTTL != 0 && hasExpire {
+		setExpire(key, keepExpire)
+	}
+	return res
+}
+
+// GET key
+func cmdGET(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs(CommandGet)
+	}
+	value, exists, err := lookupString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exists {
+		return constants.RespNil
+	}
+	return Encode(value, false)
+}
+
+// GETDEL key
+func cmdGETDEL(args []string) []byte {
+	if len(args) != 1 {
+		return respWrongNumberOfArgs("GETDEL")
+	}
+	value, exists, err := lookupString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exists {
+		return constants.RespNil
+	}
+	deleteKey(args[0])
+	return Encode(value, false)
+}
+
+// GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func cmdGETEX(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("GETEX")
+	}
+	key := args[0]
+	flags, expireAt, err := parseSetOptions(args[1:], setInExpire|getExPersist, "GETEX")
+	if err != nil {
+		return Encode(err, false)
+	}
+	value, exists, err := lookupString(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exists {
+		return constants.RespNil
+	}
+	if flags&setInExpire != 0 {
+		setExpire(key, expireAt)
+	} else if flags&getExPersist != 0 {
+		removeExpire(key)
+	}
+	return Encode(value, false)
+}
+
+// SETNX key value
+func cmdSETNX(args []string) []byte {
+	if len(args) != 2 {
+		return respWrongNumberOfArgs("SETNX")
+	}
+	if lookupKey(args[0]) != nil {
+		return constants.RespZero
+	}
+	setKey(args[0], newStringObj(args[1]))
+	return constants.RespOne
+}
+
+// SETEX key seconds value
+func cmdSETEX(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("SETEX")
+	}
+	return setWithExpire(args[0], args[2], "EX", args[1], "SETEX")
+}
+
+// PSETEX key milliseconds value
+func cmdPSETEX(args []string) []byte {
+	if len(args) != 3 {
+		return respWrongNumberOfArgs("PSETEX")
+	}
+	return setWithExpire(args[0], args[2], "PX", args[1], "PSETEX")
+}
+
+func setWithExpire(key string, value string, unit string, ttl string, cmdName string) []byte {
+	expireAt, err := parseExpireTime(unit, ttl, cmdName)
+	if err != nil {
+		return Encode(err, false)
+	}
+	setKey(key, newStringObj(value))
+	setExpire(key, expireAt)
+	return constants.RespOk
+}
+
+// MGET key [key ...]
+// Keys that do not exist or do not hold a string are reported as nil.
+func cmdMGET(args []string) []byte {
+	if len(args) < 1 {
+		return respWrongNumberOfArgs("MGET")
+	}
+	res := make([]interface{}, len(args))
+	for i, key := range args {
+		if value, exists, err := lookupString(key); err == nil && exists {
+			res[i] = value
+		}
+	}
+	return Encode(res, false)
+}
+
+// MSET key value [key value ...]
+func cmdMSET(args []string) []byte {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return respWrongNumberOfArgs("MSET")
+	}
+	for i := 0; i < len(args); i += 2 {
+		setKey(args[i], newStringObj(args[i+1]))
+	}
+	return constants.RespOk
+}
+
+// MSETNX key value [key value ...]
+// Nothing is set if any of the keys already exists.
+func cmdMSETNX(args []string) []byte {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return respWrongNumberOfArgs("MSETNX")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if lookupKey(args[i]) != nil {
+			return constants.RespZero
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		setKey(args[i], newStringObj(args[i+1]))
+	}
+	return constants.RespOne
+}
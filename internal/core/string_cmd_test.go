@@ -0,0 +1,76 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSET_Options(t *testing.T) {
+	syntaxErr := "-" + errSyntax.Error() + "\r\n"
+	invalidExpire := "-ERR invalid expire time in 'set' command\r\n"
+	wrongType := "-" + ErrWrongType.Error() + "\r\n"
+
+	tests := []struct {
+		name  string
+		setup func()
+		args  []string
+		want  string
+		// value and remaining time to live in seconds of the key afterwards, ttl -2 if missing
+		value string
+		ttl   int64
+	}{
+		{"plain", nil, []string{"k", "v"}, "+OK\r\n", "v", -1},
+		{"NX on missing key", nil, []string{"k", "v", "NX"}, "+OK\r\n", "v", -1},
+		{"NX on existing key", func() { cmdSET([]string{"k", "old"}) },
+			[]string{"k", "v", "NX"}, "$-1\r\n", "old", -1},
+		{"XX on missing key", nil, []string{"k", "v", "XX"}, "$-1\r\n", "", -2},
+		{"XX on existing key", func() { cmdSET([]string{"k", "old"}) },
+			[]string{"k", "v", "xx"}, "+OK\r\n", "v", -1},
+		{"NX and XX", nil, []string{"k", "v", "NX", "XX"}, syntaxErr, "", -2},
+		{"GET on missing key", nil, []string{"k", "v", "GET"}, "$-1\r\n", "v", -1},
+		{"GET returns the old value", func() { cmdSET([]string{"k", "old"}) },
+			[]string{"k", "v", "GET"}, "$3\r\nold\r\n", "v", -1},
+		{"NX GET on existing key", func() { cmdSET([]string{"k", "old"}) },
+			[]string{"k", "v", "NX", "GET"}, "$3\r\nold\r\n", "old", -1},
+		{"GET on another type", func() { cmdLPUSH([]string{"k", "a"}) },
+			[]string{"k", "v", "GET"}, wrongType, "", -2},
+		{"EX", nil, []string{"k", "v", "EX", "100"}, "+OK\r\n", "v", 100},
+		{"PX", nil, []string{"k", "v", "PX", "100000"}, "+OK\r\n", "v", 100},
+		{"EX drops the previous ttl", func() { cmdSET([]string{"k", "old", "EX", "100"}) },
+			[]string{"k", "v"}, "+OK\r\n", "v", -1},
+		{"KEEPTTL", func() { cmdSET([]string{"k", "old", "EX", "100"}) },
+			[]string{"k", "v", "KEEPTTL"}, "+OK\r\n", "v", 100},
+		{"KEEPTTL and EX", nil, []string{"k", "v", "KEEPTTL", "EX", "10"}, syntaxErr, "", -2},
+		{"EX and KEEPTTL", nil, []string{"k", "v", "EX", "10", "KEEPTTL"}, syntaxErr, "", -2},
+		{"EX and PX", nil, []string{"k", "v", "EX", "10", "PX", "10"}, syntaxErr, "", -2},
+		{"EX without value", nil, []string{"k", "v", "EX"}, syntaxErr, "", -2},
+		{"EX zero", nil, []string{"k", "v", "EX", "0"}, invalidExpire, "", -2},
+		{"PX negative", nil, []string{"k", "v", "PX", "-5"}, invalidExpire, "", -2},
+		{"EX overflowing", nil, []string{"k", "v", "EX", "9223372036854775807"}, invalidExpire, "", -2},
+		{"EX not an integer", nil, []string{"k", "v", "EX", "1.5"}, "-" + errNotInteger.Error() + "\r\n", "", -2},
+		{"unknown option", nil, []string{"k", "v", "FOO"}, syntaxErr, "", -2},
+	}
+	for _, tc := range tests {
+		deleteKey("k")
+		if tc.setup != nil {
+			tc.setup()
+		}
+		assert.EqualValues(t, tc.want, string(cmdSET(tc.args)), tc.name)
+		if tc.ttl == -2 {
+			if tc.want != wrongType {
+				assert.Nil(t, lookupKey("k"), tc.name)
+			}
+			continue
+		}
+		value, _, _ := lookupString("k")
+		assert.EqualValues(t, tc.value, value, tc.name)
+		assert.EqualValues(t, Encode(tc.ttl, false), cmdTTL([]string{"k"}), tc.name)
+	}
+
+	assert.EqualValues(t, "+OK\r\n", string(cmdSET([]string{"k", "v", "EXAT", "99999999999"})))
+	assert.EqualValues(t, ":99999999999\r\n", string(cmdEXPIRETIME([]string{"k"})))
+	assert.EqualValues(t, "+OK\r\n", string(cmdSET([]string{"k", "v", "PXAT", "99999999999999"})))
+	assert.EqualValues(t, ":99999999999999\r\n", string(cmdPEXPIRETIME([]string{"k"})))
+	deleteKey("k")
+}
@@ -1,63 +1,331 @@
 package core
 
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"memkv/internal/config"
+)
+
 const (
-	ZAddInNX = 1 << 1 // Only add new elements. Don't update already existing elements
-	ZAddInXX = 1 << 2 // Only update elements that already exist. Don't add new element
+	ZAddInIncr = 1 << 0 // Increment the score instead of setting it
+	ZAddInNX   = 1 << 1 // Only add new elements. Don't update already existing elements
+	ZAddInXX   = 1 << 2 // Only update elements that already exist. Don't add new element
+	ZAddInGT   = 1 << 3 // Only update existing elements if the new score is greater than the current one
+	ZAddInLT   = 1 << 4 // Only update existing elements if the new score is less than the current one
 )
 const (
 	ZAddOutNop     = 1 << 0 // Operation not performed because of conditional
 	ZAddOutAdded   = 1 << 1 // The element was new and was added
 	ZAddOutUpdated = 1 << 2 // The element already existed, score updated
+	ZAddOutNaN     = 1 << 3 // Only touched when the resulting score is NaN, nothing was done
+)
+
+// ZElement is a member of a sorted set along with its score
+type ZElement struct {
+	Ele   string
+	Score float64
+}
+
+// ZRangeSpec is a score range, each bound can be inclusive or exclusive
+type ZRangeSpec struct {
+	Min, Max     float64
+	MinEx, MaxEx bool // exclusive bounds
+}
+
+func (r *ZRangeSpec) valueGteMin(v float64) bool {
+	if r.MinEx {
+		return v > r.Min
+	}
+	return v >= r.Min
+}
+
+func (r *ZRangeSpec) valueLteMax(v float64) bool {
+	if r.MaxEx {
+		return v < r.Max
+	}
+	return v <= r.Max
+}
+
+func (r *ZRangeSpec) elementGteMin(e ZElement) bool { return r.valueGteMin(e.Score) }
+func (r *ZRangeSpec) elementLteMax(e ZElement) bool { return r.valueLteMax(e.Score) }
+
+func (r *ZRangeSpec) isEmpty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+// ZLexRangeSpec is a lexicographical range, "-" and "+" are the infinite bounds
+type ZLexRangeSpec struct {
+	Min, Max     string
+	MinEx, MaxEx bool // exclusive bounds
+	// MinInf and MaxInf are -1 for "-", 1 for "+" and 0 for a regular bound
+	MinInf, MaxInf int
+}
+
+func (r *ZLexRangeSpec) valueGteMin(v string) bool {
+	if r.MinInf != 0 {
+		return r.MinInf < 0
+	}
+	if r.MinEx {
+		return v > r.Min
+	}
+	return v >= r.Min
+}
+
+func (r *ZLexRangeSpec) valueLteMax(v string) bool {
+	if r.MaxInf != 0 {
+		return r.MaxInf > 0
+	}
+	if r.MaxEx {
+		return v < r.Max
+	}
+	return v <= r.Max
+}
+
+func (r *ZLexRangeSpec) elementGteMin(e ZElement) bool { return r.valueGteMin(e.Ele) }
+func (r *ZLexRangeSpec) elementLteMax(e ZElement) bool { return r.valueLteMax(e.Ele) }
+
+func (r *ZLexRangeSpec) isEmpty() bool {
+	if r.MinInf > 0 || r.MaxInf < 0 {
+		return true
+	}
+	if r.MinInf < 0 || r.MaxInf > 0 {
+		return false
+	}
+	cmp := strings.Compare(r.Min, r.Max)
+	return cmp > 0 || (cmp == 0 && (r.MinEx || r.MaxEx))
+}
+
+var (
+	errMinMaxNotFloat     = errors.New("ERR min or max is not a float")
+	errMinMaxNotLexString = errors.New("ERR min or max not valid string range item")
 )
 
+// ParseRangeSpec parses score bounds such as "1.5", "(1.5", "-inf" or "+inf"
+func ParseRangeSpec(min string, max string) (*ZRangeSpec, error) {
+	rng := &ZRangeSpec{}
+	var err error
+	if rng.Min, rng.MinEx, err = parseScoreBound(min); err != nil {
+		return nil, err
+	}
+	if rng.Max, rng.MaxEx, err = parseScoreBound(max); err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
+
+func parseScoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	v, ok := parseDouble(s)
+	if !ok {
+		return 0, false, errMinMaxNotFloat
+	}
+	return v, exclusive, nil
+}
+
+// ParseLexRangeSpec parses lexicographical bounds such as "[a", "(a", "-" or "+"
+func ParseLexRangeSpec(min string, max string) (*ZLexRangeSpec, error) {
+	rng := &ZLexRangeSpec{}
+	var err error
+	if rng.Min, rng.MinEx, rng.MinInf, err = parseLexBound(min); err != nil {
+		return nil, err
+	}
+	if rng.Max, rng.MaxEx, rng.MaxInf, err = parseLexBound(max); err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
+
+func parseLexBound(s string) (string, bool, int, error) {
+	if len(s) == 0 {
+		return "", false, 0, errMinMaxNotLexString
+	}
+	switch s[0] {
+	case '+':
+		if len(s) == 1 {
+			return "", false, 1, nil
+		}
+	case '-':
+		if len(s) == 1 {
+			return "", false, -1, nil
+		}
+	case '(':
+		return s[1:], true, 0, nil
+	case '[':
+		return s[1:], false, 0, nil
+	}
+	return "", false, 0, errMinMaxNotLexString
+}
+
 type ZSet struct {
+	// lp holds the elements while the set uses the listpack encoding, zskiplist and dict
+	// are nil until it is converted
+	lp        *Listpack
 	zskiplist *Skiplist
-	// map from ele to score
-	dict map[string]float64
+	// map from ele to an Obj holding its score, a Dict so that ZSCAN gets a stable cursor
+	dict *Dict
 }
 
 func (zs *ZSet) Add(score float64, ele string, flag int) (int, int) {
+	ret, outFlag, _ := zs.AddWithResult(score, ele, flag)
+	return ret, outFlag
+}
+
+// AddWithResult adds or updates ele according to the ZAddIn flags. ret is 1 unless the
+// operation is invalid, outFlag tells what was done and newScore is the score of ele
+// afterwards, which is what ZADD INCR and ZINCRBY reply with.
+func (zs *ZSet) AddWithResult(score float64, ele string, flag int) (ret int, outFlag int, newScore float64) {
+	incr := flag&ZAddInIncr != 0
 	nx := flag & ZAddInNX
 	xx := flag & ZAddInXX
+	gt := flag&ZAddInGT != 0
+	lt := flag&ZAddInLT != 0
 
+	if math.IsNaN(score) {
+		return 0, ZAddOutNaN, 0
+	}
 	if len(ele) == 0 {
-		return 0, ZAddOutNop
+		return 0, ZAddOutNop, 0
 	}
 
-	if curScore, exist := zs.dict[ele]; exist {
+	if curScore, exist := zs.lookupScore(ele); exist {
 		if nx != 0 {
-			return 1, ZAddOutNop
+			return 1, ZAddOutNop, curScore
+		}
+		if incr {
+			score += curScore
+			if math.IsNaN(score) {
+				// e.g. +inf incremented by -inf
+				return 0, ZAddOutNaN, curScore
+			}
+		}
+		if (lt && score >= curScore) || (gt && score <= curScore) {
+			return 1, ZAddOutNop, curScore
 		}
 		if curScore != score {
-			znode := zs.zskiplist.UpdateScore(curScore, ele, score)
-			zs.dict[ele] = znode.score
-			return 1, ZAddOutUpdated
+			zs.updateScore(ele, curScore, score)
+			return 1, ZAddOutUpdated, score
+		}
+		if incr {
+			// incremented by 0, the command did run even though nothing changed
+			return 1, 0, curScore
 		}
-		return 1, ZAddOutNop
+		return 1, ZAddOutNop, curScore
 	}
 
 	if xx != 0 {
-		return 1, ZAddOutNop
+		return 1, ZAddOutNop, 0
+	}
+
+	zs.insert(score, ele)
+	return 1, ZAddOutAdded, score
+}
+
+func (zs *ZSet) lookupScore(ele string) (float64, bool) {
+	if zs.lp != nil {
+		rank, score := zzlFind(zs.lp, ele)
+		return score, rank >= 0
+	}
+	return zs.dictScore(ele)
+}
+
+// dictScore returns the score of ele in a skiplist encoded set
+func (zs *ZSet) dictScore(ele string) (float64, bool) {
+	obj, ok := zs.dict.Get(ele)
+	if !ok {
+		return 0, false
+	}
+	return obj.Value.(float64), true
+}
+
+// newScoreObj wraps a score for the dict of a skiplist encoded set
+func newScoreObj(score float64) *Obj {
+	return &Obj{Value: score}
+}
+
+// insert adds ele, which must not be in the set yet, converting the set to a skiplist
+// first when it would outgrow the listpack limits
+func (zs *ZSet) insert(score float64, ele string) {
+	if zs.lp != nil && (zs.Len()+1 > config.ZSetMaxListpackEntries || len(ele) > config.ZSetMaxListpackValue) {
+		zs.convertToSkiplist()
+	}
+	if zs.lp != nil {
+		zzlInsert(zs.lp, ele, score)
+		return
+	}
+	zs.zskiplist.Insert(score, ele)
+	zs.dict.Set(ele, newScoreObj(score))
+}
+
+func (zs *ZSet) updateScore(ele string, curScore float64, score float64) {
+	if zs.lp != nil {
+		// the element most likely moves, remove it and insert it again at its new place
+		rank, _ := zzlFind(zs.lp, ele)
+		zs.lp.Delete(2*rank, 2)
+		zzlInsert(zs.lp, ele, score)
+		return
+	}
+	zs.zskiplist.UpdateScore(curScore, ele, score)
+	zs.dict.Set(ele, newScoreObj(score))
+}
+
+// convertToSkiplist moves the elements of a listpack encoded set to a skiplist and a dict
+func (zs *ZSet) convertToSkiplist() {
+	zs.zskiplist = CreateSkipList()
+	zs.dict = CreateDict()
+	for p := zs.lp.First(); p != -1; p = zs.lp.Next(zs.lp.Next(p)) {
+		ele, score := zs.lp.Get(p), zzlScore(zs.lp, zs.lp.Next(p))
+		zs.zskiplist.Insert(score, ele)
+		zs.dict.Set(ele, newScoreObj(score))
 	}
+	zs.lp = nil
+}
 
-	znode := zs.zskiplist.Insert(score, ele)
-	zs.dict[ele] = znode.score
-	return 1, ZAddOutAdded
+// Encoding reports whether the set is stored as a listpack or as a skiplist
+func (zs *ZSet) Encoding() ObjEncoding {
+	if zs.lp != nil {
+		return ObjEncodingListpack
+	}
+	return ObjEncodingSkiplist
 }
 
 func (zs *ZSet) Del(ele string) int {
-	score, exists := zs.dict[ele]
+	if zs.lp != nil {
+		rank, _ := zzlFind(zs.lp, ele)
+		if rank < 0 {
+			return 0
+		}
+		zs.lp.Delete(2*rank, 2)
+		return 1
+	}
+	score, exists := zs.dictScore(ele)
 	if !exists {
 		return 0
 	}
-	delete(zs.dict, ele)
+	zs.dict.Delete(ele)
 	zs.zskiplist.Delete(score, ele)
 	return 1
 }
 
 func (zs *ZSet) GetRank(ele string, reverse bool) (rank int64, score float64) {
+	if zs.lp != nil {
+		r, score := zzlFind(zs.lp, ele)
+		if r < 0 {
+			return -1, 0
+		}
+		if reverse {
+			r = zs.Len() - 1 - r
+		}
+		return int64(r), score
+	}
 	setSize := zs.zskiplist.length
-	score, exists := zs.dict[ele]
+	score, exists := zs.dictScore(ele)
 	if !exists {
 		return -1, 0
 	}
@@ -71,7 +339,7 @@ func (zs *ZSet) GetRank(ele string, reverse bool) (rank int64, score float64) {
 }
 
 func (zs *ZSet) GetScore(ele string) (int, float64) {
-	score, exist := zs.dict[ele]
+	score, exist := zs.lookupScore(ele)
 	if !exist {
 		return -1, 0
 	}
@@ -79,13 +347,354 @@ func (zs *ZSet) GetScore(ele string) (int, float64) {
 }
 
 func (zs *ZSet) Len() int {
-	return len(zs.dict)
+	if zs.lp != nil {
+		return zs.lp.Len() / 2
+	}
+	return zs.dict.Len()
 }
 
 func CreateZSet() *ZSet {
 	zs := ZSet{
 		zskiplist: CreateSkipList(),
-		dict:      map[string]float64{},
+		dict:      CreateDict(),
 	}
 	return &zs
 }
+
+// createZSetFor creates a sorted set for about size elements whose longest member is
+// maxEleLen bytes long, as a listpack when they fit in the configured limits
+func createZSetFor(size int, maxEleLen int) *ZSet {
+	if size <= config.ZSetMaxListpackEntries && maxEleLen <= config.ZSetMaxListpackValue {
+		return &ZSet{lp: NewListpack()}
+	}
+	return CreateZSet()
+}
+
+// RangeByRank returns the elements between the 0-based ranks start and end, both included.
+// With reverse, ranks count from the highest score.
+func (zs *ZSet) RangeByRank(start int64, end int64, reverse bool) []ZElement {
+	length := int64(zs.Len())
+	if start < 0 || start > end || start >= length {
+		return nil
+	}
+	end = min(end, length-1)
+
+	if zs.lp != nil {
+		elements := zzlElements(zs.lp)
+		if reverse {
+			res := elements[length-1-end : length-start]
+			slices.Reverse(res)
+			return res
+		}
+		return elements[start : end+1]
+	}
+
+	res := make([]ZElement, 0, end-start+1)
+	var x *SkipListNode
+	if reverse {
+		x = zs.zskiplist.GetElementByRank(uint32(length - start))
+	} else {
+		x = zs.zskiplist.GetElementByRank(uint32(start + 1))
+	}
+	for i := start; i <= end && x != nil; i++ {
+		res = append(res, ZElement{Ele: x.ele, Score: x.score})
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.levels[0].forward
+		}
+	}
+	return res
+}
+
+// RangeByScore returns the elements in the score range, skipping the first offset ones and
+// returning at most limit, a negative limit returns all of them.
+func (zs *ZSet) RangeByScore(rng *ZRangeSpec, reverse bool, offset int64, limit int64) []ZElement {
+	if zs.lp != nil {
+		return zzlRange(zs.lp, rng.elementGteMin, rng.elementLteMax, reverse, offset, limit)
+	}
+	var x *SkipListNode
+	if reverse {
+		x = zs.zskiplist.LastInRange(rng)
+	} else {
+		x = zs.zskiplist.FirstInRange(rng)
+	}
+	x = zs.skipNodes(x, reverse, offset)
+
+	var res []ZElement
+	for x != nil && limit != 0 {
+		if reverse && !rng.valueGteMin(x.score) || !reverse && !rng.valueLteMax(x.score) {
+			break
+		}
+		res = append(res, ZElement{Ele: x.ele, Score: x.score})
+		limit--
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.levels[0].forward
+		}
+	}
+	return res
+}
+
+// RangeByLex is RangeByScore for a lexicographical range
+func (zs *ZSet) RangeByLex(rng *ZLexRangeSpec, reverse bool, offset int64, limit int64) []ZElement {
+	if zs.lp != nil {
+		return zzlRange(zs.lp, rng.elementGteMin, rng.elementLteMax, reverse, offset, limit)
+	}
+	var x *SkipListNode
+	if reverse {
+		x = zs.zskiplist.LastInLexRange(rng)
+	} else {
+		x = zs.zskiplist.FirstInLexRange(rng)
+	}
+	x = zs.skipNodes(x, reverse, offset)
+
+	var res []ZElement
+	for x != nil && limit != 0 {
+		if reverse && !rng.valueGteMin(x.ele) || !reverse && !rng.valueLteMax(x.ele) {
+			break
+		}
+		res = append(res, ZElement{Ele: x.ele, Score: x.score})
+		limit--
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.levels[0].forward
+		}
+	}
+	return res
+}
+
+// skipNodes moves offset nodes away from x using ranks, rather than walking the list
+func (zs *ZSet) skipNodes(x *SkipListNode, reverse bool, offset int64) *SkipListNode {
+	if x == nil || offset <= 0 {
+		return x
+	}
+	rank := int64(zs.zskiplist.GetRank(x.score, x.ele))
+	if reverse {
+		rank -= offset
+	} else {
+		rank += offset
+	}
+	if rank < 1 || rank > int64(zs.zskiplist.length) {
+		return nil
+	}
+	return zs.zskiplist.GetElementByRank(uint32(rank))
+}
+
+// CountInRange returns how many elements have a score in the range. It only needs the ranks
+// of the first and last element of the range, so it is O(log n) whatever the count is.
+func (zs *ZSet) CountInRange(rng *ZRangeSpec) int64 {
+	if zs.lp != nil {
+		first, last := zzlRangeBounds(zzlElements(zs.lp), rng.elementGteMin, rng.elementLteMax)
+		return int64(max(last-first+1, 0))
+	}
+	first := zs.zskiplist.FirstInRange(rng)
+	if first == nil {
+		return 0
+	}
+	last := zs.zskiplist.LastInRange(rng)
+	return zs.rankDistance(first, last)
+}
+
+// CountInLexRange is CountInRange for a lexicographical range
+func (zs *ZSet) CountInLexRange(rng *ZLexRangeSpec) int64 {
+	if zs.lp != nil {
+		first, last := zzlRangeBounds(zzlElements(zs.lp), rng.elementGteMin, rng.elementLteMax)
+		return int64(max(last-first+1, 0))
+	}
+	first := zs.zskiplist.FirstInLexRange(rng)
+	if first == nil {
+		return 0
+	}
+	last := zs.zskiplist.LastInLexRange(rng)
+	return zs.rankDistance(first, last)
+}
+
+// rankDistance returns the number of nodes from first to last, both included
+func (zs *ZSet) rankDistance(first *SkipListNode, last *SkipListNode) int64 {
+	firstRank := int64(zs.zskiplist.GetRank(first.score, first.ele))
+	lastRank := int64(zs.zskiplist.GetRank(last.score, last.ele))
+	return lastRank - firstRank + 1
+}
+
+// Pop removes and returns up to count elements with the lowest scores, or the highest ones
+// when fromMax is set, taken straight from the head or the tail of the skiplist.
+func (zs *ZSet) Pop(count int64, fromMax bool) []ZElement {
+	if zs.lp != nil {
+		length := int64(zs.Len())
+		n := min(count, length)
+		if n <= 0 {
+			return []ZElement{}
+		}
+		if fromMax {
+			res := zs.RangeByRank(length-n, length-1, false)
+			slices.Reverse(res)
+			zs.lp.Delete(int(2*(length-n)), int(2*n))
+			return res
+		}
+		res := zs.RangeByRank(0, n-1, false)
+		zs.lp.Delete(0, int(2*n))
+		return res
+	}
+	res := make([]ZElement, 0, min(count, int64(zs.Len())))
+	for int64(len(res)) < count {
+		x := zs.zskiplist.head.levels[0].forward
+		if fromMax {
+			x = zs.zskiplist.tail
+		}
+		if x == nil {
+			break
+		}
+		res = append(res, ZElement{Ele: x.ele, Score: x.score})
+		zs.Del(x.ele)
+	}
+	return res
+}
+
+// ForEach calls fn for every element in ascending order until it returns false
+func (zs *ZSet) ForEach(fn func(ele string, score float64) bool) {
+	if zs.lp != nil {
+		for p := zs.lp.First(); p != -1; p = zs.lp.Next(zs.lp.Next(p)) {
+			if !fn(zs.lp.Get(p), zzlScore(zs.lp, zs.lp.Next(p))) {
+				return
+			}
+		}
+		return
+	}
+	for x := zs.zskiplist.head.levels[0].forward; x != nil; x = x.levels[0].forward {
+		if !fn(x.ele, x.score) {
+			return
+		}
+	}
+}
+
+// DeleteRangeByScore removes the elements with a score in the range and returns how many
+func (zs *ZSet) DeleteRangeByScore(rng *ZRangeSpec) int64 {
+	if zs.lp != nil {
+		return zzlDeleteRange(zs.lp, rng.elementGteMin, rng.elementLteMax)
+	}
+	return int64(zs.zskiplist.DeleteRangeByScore(rng, zs.forget))
+}
+
+// DeleteRangeByLex removes the elements in the lexicographical range and returns how many
+func (zs *ZSet) DeleteRangeByLex(rng *ZLexRangeSpec) int64 {
+	if zs.lp != nil {
+		return zzlDeleteRange(zs.lp, rng.elementGteMin, rng.elementLteMax)
+	}
+	return int64(zs.zskiplist.DeleteRangeByLex(rng, zs.forget))
+}
+
+// DeleteRangeByRank removes the elements between the 0-based ranks start and end, both
+// included, and returns how many
+func (zs *ZSet) DeleteRangeByRank(start int64, end int64) int64 {
+	length := int64(zs.Len())
+	if start < 0 || start > end || start >= length {
+		return 0
+	}
+	end = min(end, length-1)
+	if zs.lp != nil {
+		zs.lp.Delete(int(2*start), int(2*(end-start+1)))
+		return end - start + 1
+	}
+	return int64(zs.zskiplist.DeleteRangeByRank(uint32(start+1), uint32(end+1), zs.forget))
+}
+
+// forget drops ele from the dict once its skiplist node is gone
+func (zs *ZSet) forget(ele string) {
+	zs.dict.Delete(ele)
+}
+
+// zrandmemberShuffleRatio is how large count must be, relative to the set size, for
+// RandomElements to shuffle the ranks instead of drawing random ones until enough are distinct
+const zrandmemberShuffleRatio = 3
+
+// RandomElements returns count elements picked uniformly through the rank index. With
+// repeat the same element can be returned more than once and exactly count elements are
+// returned, otherwise they are distinct and there are at most Len() of them.
+func (zs *ZSet) RandomElements(count int64, repeat bool) []ZElement {
+	length := int64(zs.Len())
+	if length == 0 || count <= 0 {
+		return nil
+	}
+	elementAt := func(rank int64) ZElement {
+		if zs.lp != nil {
+			return zzlElementAt(zs.lp, int(rank))
+		}
+		x := zs.zskiplist.GetElementByRank(uint32(rank + 1))
+		return ZElement{Ele: x.ele, Score: x.score}
+	}
+
+	if repeat {
+		// count comes from the client, the reply grows as elements are picked
+		res := make([]ZElement, 0, min(count, length))
+		for i := int64(0); i < count; i++ {
+			res = append(res, elementAt(rand.Int63n(length)))
+		}
+		return res
+	}
+
+	if count >= length {
+		res := zs.RangeByRank(0, length-1, false)
+		rand.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] })
+		return res
+	}
+
+	// picking random ranks until enough distinct ones come out gets slow when count is
+	// close to the size of the set, shuffle the ranks instead and keep the first count
+	if count*zrandmemberShuffleRatio > length {
+		ranks := rand.Perm(int(length))[:count]
+		res := make([]ZElement, 0, count)
+		for _, rank := range ranks {
+			res = append(res, elementAt(int64(rank)))
+		}
+		return res
+	}
+
+	picked := make(map[int64]struct{}, count)
+	res := make([]ZElement, 0, count)
+	for int64(len(res)) < count {
+		rank := rand.Int63n(length)
+		if _, ok := picked[rank]; ok {
+			continue
+		}
+		picked[rank] = struct{}{}
+		res = append(res, elementAt(rank))
+	}
+	return res
+}
+
+// Scan returns the elements of about count buckets of the dict starting at cursor and the
+// cursor of the next call, 0 once every element was returned. A listpack is small enough to
+// be returned at once.
+func (zs *ZSet) Scan(cursor uint64, count int) ([]ZElement, uint64) {
+	if zs.lp != nil {
+		return zzlElements(zs.lp), 0
+	}
+	var res []ZElement
+	maxIterations := count * 10
+	for {
+		cursor = zs.dict.Scan(cursor, func(ele string, obj *Obj) {
+			res = append(res, ZElement{Ele: ele, Score: obj.Value.(float64)})
+		})
+		maxIterations--
+		if cursor == 0 || maxIterations == 0 || len(res) >= count {
+			break
+		}
+	}
+	return res, cursor
+}
+
+// Dup returns a copy of the set with the same encoding
+func (zs *ZSet) Dup() *ZSet {
+	if zs.lp != nil {
+		return &ZSet{lp: zs.lp.Dup()}
+	}
+	dup := CreateZSet()
+	for x := zs.zskiplist.head.levels[0].forward; x != nil; x = x.levels[0].forward {
+		dup.zskiplist.Insert(x.score, x.ele)
+		dup.dict.Set(x.ele, newScoreObj(x.score))
+	}
+	return dup
+}
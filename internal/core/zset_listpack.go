@@ -0,0 +1,103 @@
+package core
+
+import "slices"
+
+// Small sorted sets are stored in a listpack as ele, score pairs kept in the same order as
+// the skiplist would: by score, then by ele. Every operation is O(n), which is cheap for
+// the few elements allowed before the set is converted to a skiplist.
+
+// zzlFind returns the rank and the score of ele, the rank is -1 when ele is not in the set
+func zzlFind(lp *Listpack, ele string) (int, float64) {
+	rank := 0
+	for p := lp.First(); p != -1; p = lp.Next(lp.Next(p)) {
+		if lp.Get(p) == ele {
+			return rank, zzlScore(lp, lp.Next(p))
+		}
+		rank++
+	}
+	return -1, 0
+}
+
+// zzlScore returns the score stored at p
+func zzlScore(lp *Listpack, p int) float64 {
+	score, _ := parseDouble(lp.Get(p))
+	return score
+}
+
+// zzlElementAt returns the element at the 0-based rank
+func zzlElementAt(lp *Listpack, rank int) ZElement {
+	p := lp.Seek(2 * rank)
+	return ZElement{Ele: lp.Get(p), Score: zzlScore(lp, lp.Next(p))}
+}
+
+// zzlInsert adds ele, which must not be in the set yet, at its place in the order
+func zzlInsert(lp *Listpack, ele string, score float64) {
+	rank := 0
+	for p := lp.First(); p != -1; p = lp.Next(lp.Next(p)) {
+		s := zzlScore(lp, lp.Next(p))
+		if s > score || (s == score && lp.Get(p) > ele) {
+			break
+		}
+		rank++
+	}
+	// integral scores are formatted as integers and get the compact integer encoding
+	lp.Insert(2*rank, ele, formatDouble(score))
+}
+
+// zzlElements decodes all the elements in order
+func zzlElements(lp *Listpack) []ZElement {
+	res := make([]ZElement, 0, lp.Len()/2)
+	for p := lp.First(); p != -1; p = lp.Next(lp.Next(p)) {
+		res = append(res, ZElement{Ele: lp.Get(p), Score: zzlScore(lp, lp.Next(p))})
+	}
+	return res
+}
+
+// zzlRangeBounds returns the ranks of the first and the last element between the bounds,
+// first is greater than last when no element is
+func zzlRangeBounds(elements []ZElement, gteMin func(e ZElement) bool, lteMax func(e ZElement) bool) (int, int) {
+	first := 0
+	for first < len(elements) && !gteMin(elements[first]) {
+		first++
+	}
+	last := len(elements) - 1
+	for last >= first && !lteMax(elements[last]) {
+		last--
+	}
+	return first, last
+}
+
+// zzlRange returns the elements of the listpack between the bounds, in reverse order with
+// reverse, skipping offset of them and returning at most limit, all of them if negative
+func zzlRange(lp *Listpack, gteMin func(e ZElement) bool, lteMax func(e ZElement) bool,
+	reverse bool, offset int64, limit int64) []ZElement {
+	elements := zzlElements(lp)
+	first, last := zzlRangeBounds(elements, gteMin, lteMax)
+	if first > last {
+		return nil
+	}
+	res := elements[first : last+1]
+	if reverse {
+		slices.Reverse(res)
+	}
+	if offset > 0 {
+		res = res[min(offset, int64(len(res))):]
+	}
+	if limit >= 0 && limit < int64(len(res)) {
+		res = res[:limit]
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// zzlDeleteRange removes the elements between the bounds and returns how many
+func zzlDeleteRange(lp *Listpack, gteMin func(e ZElement) bool, lteMax func(e ZElement) bool) int64 {
+	first, last := zzlRangeBounds(zzlElements(lp), gteMin, lteMax)
+	if first > last {
+		return 0
+	}
+	lp.Delete(2*first, 2*(last-first+1))
+	return int64(last - first + 1)
+}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,7 +33,7 @@ func TestZSet_Add_AddNew(t *testing.T) {
 	ret, flagOut := zs.Add(10.0, "k1", 0)
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
-	v, ok := zs.dict["k1"]
+	v, ok := zs.lookupScore("k1")
 	assert.True(t, ok)
 	assert.EqualValues(t, 10.0, v)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
@@ -38,7 +41,7 @@ func TestZSet_Add_AddNew(t *testing.T) {
 	assert.EqualValues(t, 1, zs.zskiplist.length)
 
 	ret, flagOut = zs.Add(20.0, "k2", 0)
-	v, ok = zs.dict["k2"]
+	v, ok = zs.lookupScore("k2")
 	assert.EqualValues(t, 1, ret)
 	assert.True(t, ok)
 	assert.EqualValues(t, 20, v)
@@ -52,7 +55,7 @@ func TestZSet_Add_UpdateExist(t *testing.T) {
 	ret, flagOut := zs.Add(10.0, "k1", 0)
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
-	v, ok := zs.dict["k1"]
+	v, ok := zs.lookupScore("k1")
 	assert.True(t, ok)
 	assert.EqualValues(t, 10.0, v)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
@@ -60,7 +63,7 @@ func TestZSet_Add_UpdateExist(t *testing.T) {
 	assert.EqualValues(t, 1, zs.zskiplist.length)
 
 	ret, flagOut = zs.Add(5.0, "k1", 0)
-	v, ok = zs.dict["k1"]
+	v, ok = zs.lookupScore("k1")
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutUpdated, flagOut)
 	assert.True(t, ok)
@@ -75,7 +78,7 @@ func TestZSet_Add_AddDuplicateEleAndScore(t *testing.T) {
 	ret, flagOut := zs.Add(10.0, "k1", 0)
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
-	v, ok := zs.dict["k1"]
+	v, ok := zs.lookupScore("k1")
 	assert.True(t, ok)
 	assert.EqualValues(t, 10.0, v)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
@@ -83,7 +86,7 @@ func TestZSet_Add_AddDuplicateEleAndScore(t *testing.T) {
 	assert.EqualValues(t, 1, zs.zskiplist.length)
 
 	ret, flagOut = zs.Add(10.0, "k1", 0)
-	v, ok = zs.dict["k1"]
+	v, ok = zs.lookupScore("k1")
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutNop, flagOut)
 	assert.True(t, ok)
@@ -162,3 +165,405 @@ func TestZSet_GetRank(t *testing.T) {
 	assert.EqualValues(t, 0, rank)
 	assert.EqualValues(t, 40.0, score)
 }
+
+func createTestZSet(elements map[string]float64) *ZSet {
+	zs := CreateZSet()
+	for ele, score := range elements {
+		zs.Add(score, ele, 0)
+	}
+	return zs
+}
+
+func zelementNames(elements []ZElement) []string {
+	res := make([]string, 0, len(elements))
+	for _, e := range elements {
+		res = append(res, e.Ele)
+	}
+	return res
+}
+
+func TestSkiplist_GetElementByRank(t *testing.T) {
+	zs := CreateZSet()
+	for i := 0; i < 100; i++ {
+		zs.Add(float64(i), fmt.Sprintf("k%03d", i), 0)
+	}
+	for rank := uint32(1); rank <= 100; rank++ {
+		x := zs.zskiplist.GetElementByRank(rank)
+		assert.EqualValues(t, rank-1, x.score)
+	}
+	assert.Nil(t, zs.zskiplist.GetElementByRank(0))
+	assert.Nil(t, zs.zskiplist.GetElementByRank(101))
+}
+
+func TestZSet_RangeByRank(t *testing.T) {
+	zs := createTestZSet(map[string]float64{"k1": 10, "k2": 20, "k3": 30, "k4": 40})
+
+	assert.EqualValues(t, []string{"k1", "k2", "k3", "k4"}, zelementNames(zs.RangeByRank(0, 3, false)))
+	assert.EqualValues(t, []string{"k2", "k3"}, zelementNames(zs.RangeByRank(1, 2, false)))
+	assert.EqualValues(t, []string{"k4", "k3"}, zelementNames(zs.RangeByRank(0, 1, true)))
+	assert.EqualValues(t, []string{"k3", "k4"}, zelementNames(zs.RangeByRank(2, 100, false)))
+	assert.Empty(t, zs.RangeByRank(4, 10, false))
+	assert.Empty(t, zs.RangeByRank(2, 1, false))
+
+	start, end := normalizeRankRange(-2, -1, 4)
+	assert.EqualValues(t, []string{"k3", "k4"}, zelementNames(zs.RangeByRank(start, end, false)))
+}
+
+func TestZSet_RangeByScore(t *testing.T) {
+	zs := createTestZSet(map[string]float64{"k1": 10, "k2": 20, "k3": 30, "k4": 40})
+
+	rng, err := ParseRangeSpec("20", "30")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"k2", "k3"}, zelementNames(zs.RangeByScore(rng, false, 0, -1)))
+	assert.EqualValues(t, []string{"k3", "k2"}, zelementNames(zs.RangeByScore(rng, true, 0, -1)))
+
+	rng, _ = ParseRangeSpec("(20", "+inf")
+	assert.EqualValues(t, []string{"k3", "k4"}, zelementNames(zs.RangeByScore(rng, false, 0, -1)))
+
+	rng, _ = ParseRangeSpec("-inf", "(40")
+	assert.EqualValues(t, []string{"k2", "k3"}, zelementNames(zs.RangeByScore(rng, false, 1, 2)))
+	assert.EqualValues(t, []string{"k2"}, zelementNames(zs.RangeByScore(rng, true, 1, 1)))
+	assert.Empty(t, zs.RangeByScore(rng, false, 3, -1))
+
+	rng, _ = ParseRangeSpec("(20", "(30")
+	assert.Empty(t, zs.RangeByScore(rng, false, 0, -1))
+	rng, _ = ParseRangeSpec("50", "60")
+	assert.Empty(t, zs.RangeByScore(rng, false, 0, -1))
+
+	_, err = ParseRangeSpec("abc", "1")
+	assert.Error(t, err)
+	_, err = ParseRangeSpec("1", "nan")
+	assert.Error(t, err)
+}
+
+func TestZSet_RangeByLex(t *testing.T) {
+	zs := createTestZSet(map[string]float64{"a": 0, "b": 0, "c": 0, "d": 0, "e": 0})
+
+	rng, err := ParseLexRangeSpec("[b", "(d")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"b", "c"}, zelementNames(zs.RangeByLex(rng, false, 0, -1)))
+	assert.EqualValues(t, []string{"c", "b"}, zelementNames(zs.RangeByLex(rng, true, 0, -1)))
+
+	rng, _ = ParseLexRangeSpec("-", "+")
+	assert.EqualValues(t, []string{"a", "b", "c", "d", "e"}, zelementNames(zs.RangeByLex(rng, false, 0, -1)))
+	assert.EqualValues(t, []string{"d", "c"}, zelementNames(zs.RangeByLex(rng, true, 1, 2)))
+
+	rng, _ = ParseLexRangeSpec("+", "-")
+	assert.Empty(t, zs.RangeByLex(rng, false, 0, -1))
+
+	_, err = ParseLexRangeSpec("b", "[d")
+	assert.Error(t, err)
+}
+
+func TestZSet_AddWithResult_Incr(t *testing.T) {
+	zs := CreateZSet()
+	ret, flagOut, score := zs.AddWithResult(5, "k1", ZAddInIncr)
+	assert.EqualValues(t, 1, ret)
+	assert.EqualValues(t, ZAddOutAdded, flagOut)
+	assert.EqualValues(t, 5, score)
+
+	ret, flagOut, score = zs.AddWithResult(2.5, "k1", ZAddInIncr)
+	assert.EqualValues(t, 1, ret)
+	assert.EqualValues(t, ZAddOutUpdated, flagOut)
+	assert.EqualValues(t, 7.5, score)
+
+	ret, flagOut, score = zs.AddWithResult(0, "k1", ZAddInIncr)
+	assert.EqualValues(t, 1, ret)
+	assert.EqualValues(t, 0, flagOut)
+	assert.EqualValues(t, 7.5, score)
+
+	zs.Add(math.Inf(1), "k2", 0)
+	ret, flagOut, _ = zs.AddWithResult(math.Inf(-1), "k2", ZAddInIncr)
+	assert.EqualValues(t, 0, ret)
+	assert.EqualValues(t, ZAddOutNaN, flagOut)
+	assert.EqualValues(t, math.Inf(1), scoreOf(zs, "k2"))
+
+	ret, flagOut, _ = zs.AddWithResult(math.NaN(), "k3", 0)
+	assert.EqualValues(t, 0, ret)
+	assert.EqualValues(t, ZAddOutNaN, flagOut)
+}
+
+func TestZSet_Add_GTLT(t *testing.T) {
+	zs := CreateZSet()
+	_, flagOut := zs.Add(10, "k1", ZAddInGT)
+	assert.EqualValues(t, ZAddOutAdded, flagOut)
+
+	_, flagOut = zs.Add(5, "k1", ZAddInGT)
+	assert.EqualValues(t, ZAddOutNop, flagOut)
+	_, flagOut = zs.Add(15, "k1", ZAddInGT)
+	assert.EqualValues(t, ZAddOutUpdated, flagOut)
+
+	_, flagOut = zs.Add(20, "k1", ZAddInLT)
+	assert.EqualValues(t, ZAddOutNop, flagOut)
+	_, flagOut = zs.Add(1, "k1", ZAddInLT)
+	assert.EqualValues(t, ZAddOutUpdated, flagOut)
+	assert.EqualValues(t, 1, scoreOf(zs, "k1"))
+
+	_, flagOut, score := zs.AddWithResult(-5, "k1", ZAddInGT|ZAddInIncr)
+	assert.EqualValues(t, ZAddOutNop, flagOut)
+	assert.EqualValues(t, 1, score)
+}
+
+func TestZSet_CountInRange(t *testing.T) {
+	zs := CreateZSet()
+	for i := 1; i <= 100; i++ {
+		zs.Add(float64(i), fmt.Sprintf("k%03d", i), 0)
+	}
+
+	cases := []struct {
+		min, max string
+		count    int64
+	}{
+		{"-inf", "+inf", 100},
+		{"1", "100", 100},
+		{"(1", "100", 99},
+		{"(1", "(100", 98},
+		{"10", "19", 10},
+		{"10.5", "11.5", 1},
+		{"10.1", "10.9", 0},
+		{"50", "50", 1},
+		{"(50", "50", 0},
+		{"200", "+inf", 0},
+		{"-inf", "0", 0},
+		{"60", "40", 0},
+	}
+	for _, c := range cases {
+		rng, err := ParseRangeSpec(c.min, c.max)
+		assert.NoError(t, err)
+		assert.EqualValues(t, c.count, zs.CountInRange(rng), "%s %s", c.min, c.max)
+	}
+}
+
+func TestZSet_CountInLexRange(t *testing.T) {
+	zs := createTestZSet(map[string]float64{"a": 0, "b": 0, "c": 0, "d": 0, "e": 0})
+
+	cases := []struct {
+		min, max string
+		count    int64
+	}{
+		{"-", "+", 5},
+		{"[b", "[d", 3},
+		{"(b", "[d", 2},
+		{"(b", "(d", 1},
+		{"[aa", "(c", 1},
+		{"-", "(a", 0},
+		{"(e", "+", 0},
+		{"[d", "[b", 0},
+		{"+", "-", 0},
+	}
+	for _, c := range cases {
+		rng, err := ParseLexRangeSpec(c.min, c.max)
+		assert.NoError(t, err)
+		assert.EqualValues(t, c.count, zs.CountInLexRange(rng), "%s %s", c.min, c.max)
+	}
+}
+
+func TestZSet_DeleteRangeByRank(t *testing.T) {
+	zs := createNumberedZSet(10)
+	assert.EqualValues(t, 3, zs.DeleteRangeByRank(2, 4))
+	assert.EqualValues(t, 7, zs.Len())
+	assert.EqualValues(t, []string{"m0", "m1", "m5", "m6", "m7", "m8", "m9"}, zelementNames(zs.RangeByRank(0, int64(zs.Len())-1, false)))
+	// ranks must still be right after the spans were updated
+	for i, ele := range []string{"m0", "m1", "m5", "m6", "m7", "m8", "m9"} {
+		assert.EqualValues(t, i+1, zs.zskiplist.GetRank(scoreOf(zs, ele), ele))
+	}
+	ret, _ := zs.GetScore("m3")
+	assert.EqualValues(t, -1, ret)
+
+	assert.EqualValues(t, 2, zs.DeleteRangeByRank(5, 100))
+	assert.EqualValues(t, 0, zs.DeleteRangeByRank(5, 100))
+	assert.EqualValues(t, 5, zs.Len())
+}
+
+func TestZSet_DeleteRangeByScore(t *testing.T) {
+	zs := createNumberedZSet(10)
+	assert.EqualValues(t, 4, zs.DeleteRangeByScore(&ZRangeSpec{Min: 2, Max: 6, MinEx: true}))
+	assert.EqualValues(t, []string{"m0", "m1", "m2", "m7", "m8", "m9"}, zelementNames(zs.RangeByRank(0, int64(zs.Len())-1, false)))
+	assert.EqualValues(t, 0, zs.DeleteRangeByScore(&ZRangeSpec{Min: 20, Max: 30}))
+	assert.EqualValues(t, 6, zs.DeleteRangeByScore(&ZRangeSpec{Min: math.Inf(-1), Max: math.Inf(1)}))
+	assert.EqualValues(t, 0, zs.Len())
+	assert.EqualValues(t, 0, zs.dict.Len())
+}
+
+func TestZSet_DeleteRangeByLex(t *testing.T) {
+	zs := CreateZSet()
+	for _, ele := range []string{"a", "b", "c", "d", "e"} {
+		zs.Add(0, ele, 0)
+	}
+	rng, err := ParseLexRangeSpec("[b", "(e")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, zs.DeleteRangeByLex(rng))
+	assert.EqualValues(t, []string{"a", "e"}, zelementNames(zs.RangeByRank(0, int64(zs.Len())-1, false)))
+	assert.EqualValues(t, 2, zs.zskiplist.GetRank(0, "e"))
+}
+
+// createNumberedZSet creates a sorted set of n elements named m0, m1... with the scores 0, 1...
+func createNumberedZSet(n int) *ZSet {
+	zs := CreateZSet()
+	for i := 0; i < n; i++ {
+		zs.Add(float64(i), fmt.Sprintf("m%d", i), 0)
+	}
+	return zs
+}
+
+// scoreOf returns the score of ele in a skiplist encoded set
+func scoreOf(zs *ZSet, ele string) float64 {
+	score, _ := zs.dictScore(ele)
+	return score
+}
+
+func TestZSet_RandomElements(t *testing.T) {
+	zs := createNumberedZSet(10)
+	for _, count := range []int64{1, 3, 5, 9, 10, 20} {
+		res := zs.RandomElements(count, false)
+		assert.EqualValues(t, min(count, 10), len(res))
+		seen := make(map[string]struct{})
+		for _, e := range res {
+			_, score := zs.GetScore(e.Ele)
+			assert.EqualValues(t, score, e.Score)
+			seen[e.Ele] = struct{}{}
+		}
+		assert.EqualValues(t, len(res), len(seen), "elements must be distinct")
+	}
+
+	res := zs.RandomElements(50, true)
+	assert.EqualValues(t, 50, len(res))
+	for _, e := range res {
+		ret, _ := zs.GetScore(e.Ele)
+		assert.EqualValues(t, 0, ret)
+	}
+	assert.Nil(t, CreateZSet().RandomElements(5, true))
+}
+
+func TestZRANDMEMBER_HugeCount(t *testing.T) {
+	cmdZADD([]string{"zrand", "1", "a", "2", "b"})
+	defer deleteKey("zrand")
+	outOfRange := "-" + errOutOfRange.Error() + "\r\n"
+	assert.EqualValues(t, outOfRange, string(cmdZRANDMEMBER([]string{"zrand", "-100000000000"})))
+	assert.EqualValues(t, outOfRange, string(cmdZRANDMEMBER([]string{"zrand", "-9223372036854775808"})))
+	assert.EqualValues(t, "*2\r\n", string(cmdZRANDMEMBER([]string{"zrand", "4611686018427387903"}))[:4])
+	assert.EqualValues(t, "*3\r\n", string(cmdZRANDMEMBER([]string{"zrand", "-3"}))[:4])
+}
+
+func TestZSet_ListpackMatchesSkiplist(t *testing.T) {
+	lpSet, slSet := createZSetFor(0, 0), CreateZSet()
+	assert.EqualValues(t, ObjEncodingListpack, lpSet.Encoding())
+	for i := 0; i < 40; i++ {
+		ele := fmt.Sprintf("m%02d", (i*7)%40)
+		score := float64(i%10) / 2
+		lpSet.Add(score, ele, 0)
+		slSet.Add(score, ele, 0)
+	}
+	lpSet.Add(math.Inf(1), "inf", 0)
+	slSet.Add(math.Inf(1), "inf", 0)
+	lpSet.Add(1, "m03", ZAddInIncr)
+	slSet.Add(1, "m03", ZAddInIncr)
+	assert.EqualValues(t, ObjEncodingListpack, lpSet.Encoding())
+
+	all := slSet.RangeByRank(0, int64(slSet.Len())-1, false)
+	assert.EqualValues(t, all, lpSet.RangeByRank(0, int64(lpSet.Len())-1, false))
+	assert.EqualValues(t, slSet.RangeByRank(3, 9, true), lpSet.RangeByRank(3, 9, true))
+
+	scoreRange := &ZRangeSpec{Min: 1, Max: 3, MaxEx: true}
+	assert.EqualValues(t, slSet.RangeByScore(scoreRange, false, 2, 5), lpSet.RangeByScore(scoreRange, false, 2, 5))
+	assert.EqualValues(t, slSet.RangeByScore(scoreRange, true, 0, -1), lpSet.RangeByScore(scoreRange, true, 0, -1))
+	assert.EqualValues(t, slSet.CountInRange(scoreRange), lpSet.CountInRange(scoreRange))
+
+	for _, ele := range []string{"m00", "m03", "m39", "inf", "missing"} {
+		slRank, slScore := slSet.GetRank(ele, true)
+		lpRank, lpScore := lpSet.GetRank(ele, true)
+		assert.EqualValues(t, slRank, lpRank, ele)
+		assert.EqualValues(t, slScore, lpScore, ele)
+	}
+
+	assert.EqualValues(t, slSet.Pop(3, true), lpSet.Pop(3, true))
+	assert.EqualValues(t, slSet.Pop(2, false), lpSet.Pop(2, false))
+	assert.EqualValues(t, slSet.DeleteRangeByScore(scoreRange), lpSet.DeleteRangeByScore(scoreRange))
+	assert.EqualValues(t, slSet.DeleteRangeByRank(1, 4), lpSet.DeleteRangeByRank(1, 4))
+	assert.EqualValues(t, slSet.Del("m05"), lpSet.Del("m05"))
+	assert.EqualValues(t, slSet.RangeByRank(0, int64(slSet.Len())-1, false), lpSet.RangeByRank(0, int64(lpSet.Len())-1, false))
+}
+
+func TestZSet_ListpackLexRanges(t *testing.T) {
+	// lex ranges are only meaningful when all the scores are equal
+	lpSet, slSet := createZSetFor(0, 0), CreateZSet()
+	for _, ele := range []string{"e", "a", "d", "c", "b", "f"} {
+		lpSet.Add(0, ele, 0)
+		slSet.Add(0, ele, 0)
+	}
+	lexRange, _ := ParseLexRangeSpec("(a", "[e")
+	assert.EqualValues(t, 4, lpSet.CountInLexRange(lexRange))
+	assert.EqualValues(t, slSet.RangeByLex(lexRange, true, 1, 2), lpSet.RangeByLex(lexRange, true, 1, 2))
+	assert.EqualValues(t, slSet.DeleteRangeByLex(lexRange), lpSet.DeleteRangeByLex(lexRange))
+	assert.EqualValues(t, []string{"a", "f"}, zelementNames(lpSet.RangeByRank(0, 1, false)))
+}
+
+func TestZSet_ListpackConversion(t *testing.T) {
+	zs := createZSetFor(1, 1)
+	for i := 0; i < 128; i++ {
+		zs.Add(float64(i), fmt.Sprint(i), 0)
+	}
+	assert.EqualValues(t, ObjEncodingListpack, zs.Encoding())
+	zs.Add(200, "one more", 0)
+	assert.EqualValues(t, ObjEncodingSkiplist, zs.Encoding())
+	assert.EqualValues(t, 129, zs.Len())
+	rank, score := zs.GetRank("127", false)
+	assert.EqualValues(t, 127, rank)
+	assert.EqualValues(t, 127, score)
+
+	zs = createZSetFor(1, 1)
+	zs.Add(1, "a", 0)
+	zs.Add(2, strings.Repeat("x", 65), 0)
+	assert.EqualValues(t, ObjEncodingSkiplist, zs.Encoding())
+	assert.EqualValues(t, 2, zs.Len())
+
+	assert.EqualValues(t, ObjEncodingSkiplist, createZSetFor(129, 1).Encoding())
+	assert.EqualValues(t, ObjEncodingSkiplist, createZSetFor(1, 65).Encoding())
+}
+
+func TestZSet_Dup(t *testing.T) {
+	for _, zs := range []*ZSet{createZSetFor(0, 0), createNumberedZSet(0)} {
+		for i := 0; i < 5; i++ {
+			zs.Add(float64(i), fmt.Sprint(i), 0)
+		}
+		dup := zs.Dup()
+		assert.EqualValues(t, zs.Encoding(), dup.Encoding())
+		assert.EqualValues(t, zs.RangeByRank(0, 4, false), dup.RangeByRank(0, 4, false))
+
+		dup.Del("0")
+		dup.Add(10, "1", 0)
+		assert.EqualValues(t, 5, zs.Len())
+		_, score := zs.GetScore("1")
+		assert.EqualValues(t, 1, score)
+	}
+}
+
+func TestZSet_ScanWhileDeleting(t *testing.T) {
+	zs := createNumberedZSet(500)
+	assert.EqualValues(t, ObjEncodingSkiplist, zs.Encoding())
+	seen := make(map[string]struct{})
+	deleted := make(map[string]struct{})
+	cursor := uint64(0)
+	for {
+		var batch []ZElement
+		batch, cursor = zs.Scan(cursor, 10)
+		for _, e := range batch {
+			seen[e.Ele] = struct{}{}
+			assert.EqualValues(t, scoreOf(zs, e.Ele), e.Score)
+		}
+		// deleting the lowest ranked elements used to shift the others before the cursor
+		for _, e := range zs.RangeByRank(0, 4, false) {
+			zs.Del(e.Ele)
+			deleted[e.Ele] = struct{}{}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 500; i++ {
+		ele := fmt.Sprintf("m%d", i)
+		if _, ok := deleted[ele]; !ok {
+			assert.Contains(t, seen, ele)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"io"
+	"log"
+	"syscall"
+	"time"
+
+	"memkv/internal/config"
+	core "memkv/internal/core"
+)
+
+// ioBufferSize is how many bytes we try to read from a client socket at once
+const ioBufferSize = 16 * 1024
+
+// client holds the state of one accepted connection
+type client struct {
+	fd      int
+	decoder *core.RespDecoder
+	// reply holds the output not yet accepted by the socket. Replies are appended while
+	// commands run and sent by flush; whatever does not fit in the socket buffer stays
+	// here until the fd becomes writable again.
+	reply []byte
+	// waitingWrite is true while the fd is registered for write readiness
+	waitingWrite bool
+	// softLimitReachedAt is when the pending output went above the soft limit, zero if it is below
+	softLimitReachedAt time.Time
+	// closeASAP marks a client that must be disconnected, e.g. for exceeding the output limits
+	closeASAP bool
+	// blockTimerID is the timer that times out the blocking command the client waits on, 0 if none
+	blockTimerID int64
+}
+
+var _ io.ReadWriter = (*client)(nil)
+
+func newClient(fd int) *client {
+	return &client{
+		fd:      fd,
+		decoder: core.NewRespDecoder(),
+	}
+}
+
+// Read reads directly from the client socket.
+func (c *client) Read(data []byte) (int, error) {
+	return syscall.Read(c.fd, data)
+}
+
+// Write queues data in the output buffer, it is sent by flush.
+func (c *client) Write(data []byte) (int, error) {
+	if c.closeASAP {
+		// the client is going away, don't keep accumulating output for it
+		return len(data), nil
+	}
+	c.reply = append(c.reply, data...)
+	if c.outputLimitReached(time.Now()) {
+		log.Printf("client fd=%d exceeded the output buffer limits, closing it\n", c.fd)
+		c.closeASAP = true
+		c.reply = nil
+	}
+	return len(data), nil
+}
+
+// readFromSocket reads whatever is available on the socket into the decoder.
+// It returns io.EOF once the peer has closed the connection, and
+// core.ErrProtocolQueryBufferLimit when the unprocessed input grew past
+// config.ClientQueryBufferLimit, which a blocked client does while it keeps sending.
+func (c *client) readFromSocket() error {
+	var buf = make([]byte, ioBufferSize)
+	n, err := syscall.Read(c.fd, buf)
+	if err != nil {
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return nil
+		}
+		return err
+	}
+	if n == 0 {
+		return io.EOF
+	}
+	c.decoder.Feed(buf[:n])
+	if c.decoder.Buffered() > config.ClientQueryBufferLimit {
+		log.Printf("client fd=%d exceeded the query buffer limit, closing it\n", c.fd)
+		return core.ErrProtocolQueryBufferLimit
+	}
+	return nil
+}
+
+// flush writes as much of the output buffer as the socket accepts without blocking.
+// It returns true when everything has been sent.
+func (c *client) flush() (bool, error) {
+	for len(c.reply) > 0 {
+		n, err := syscall.Write(c.fd, c.reply)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.EAGAIN {
+				return false, nil
+			}
+			return false, err
+		}
+		c.reply = c.reply[n:]
+	}
+	// release the memory of large replies instead of keeping it around for the connection lifetime
+	if cap(c.reply) > ioBufferSize {
+		c.reply = nil
+	} else {
+		c.reply = c.reply[:0]
+	}
+	return true, nil
+}
+
+// outputLimitReached checks the pending output against the configured hard and soft limits.
+func (c *client) outputLimitReached(now time.Time) bool {
+	size := len(c.reply)
+	if config.ClientOutputBufferHardLimit > 0 && size >= config.ClientOutputBufferHardLimit {
+		return true
+	}
+	if config.ClientOutputBufferSoftLimit <= 0 || size < config.ClientOutputBufferSoftLimit {
+		c.softLimitReachedAt = time.Time{}
+		return false
+	}
+	if c.softLimitReachedAt.IsZero() {
+		c.softLimitReachedAt = now
+		return false
+	}
+	return now.Sub(c.softLimitReachedAt) > time.Duration(config.ClientOutputBufferSoftSeconds)*time.Second
+}
@@ -7,10 +7,13 @@ import (
 	"log"
 	"net"
 	"os"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
+	"memkv/internal/config"
 	"memkv/internal/constants"
 	core "memkv/internal/core"
 	"memkv/internal/core/processor"
@@ -18,17 +21,45 @@ import (
 
 var eStatus int32 = constants.EngineStatusWaiting
 
+const (
+	// activeExpireFastCycleDuration bounds the time spent removing expired keys before each wait
+	activeExpireFastCycleDuration = time.Millisecond
+	// activeExpireSlowCyclePercent is the share of each cron period the expire cycle may use
+	activeExpireSlowCyclePercent = 25
+	// activeExpireHashFieldsTimeLimit is how long each cron may spend removing expired hash fields
+	activeExpireHashFieldsTimeLimit = time.Millisecond
+	// activeRehashTimeLimit is how long each cron may spend resizing the keyspace hash table
+	activeRehashTimeLimit = time.Millisecond
+)
+
 // Server represents our Redis-like server
 type Server struct {
 	host string
 	port int
+	// clients is the table of connected clients keyed by their fd
+	clients     map[int]*client
+	multiplexer processor.Multiplexer
+	// timers holds the time events of the loop, Check never waits past the nearest one
+	timers     *processor.TimerQueue
+	cronPeriod time.Duration
+	// unblockedClients were served or timed out while blocked, their pending input
+	// is processed before the loop waits again
+	unblockedClients []*client
+	// wakeupFDs is a pipe monitored by the event loop so another goroutine can interrupt
+	// a blocking Check, e.g. to shut the server down
+	wakeupFDs [2]int
+	// wakeupWriteFD publishes wakeupFDs[1] to the other goroutines once the pipe is
+	// monitored, 0 before that and once the loop exited
+	wakeupWriteFD atomic.Int32
 }
 
 // NewServer creates a new server instance
 func NewServer(host string, port int) *Server {
 	return &Server{
-		host: host,
-		port: port,
+		host:    host,
+		port:    port,
+		clients: make(map[int]*client),
+		timers:  processor.NewTimerQueue(),
 	}
 }
 
@@ -39,7 +70,6 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 
 	var err error
 	events := make([]processor.Event, processor.MaxConnection)
-	clientNum := 0
 
 	// Create fd socket server
 	serverFD, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
@@ -85,6 +115,7 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 	}
 
 	defer multiplexer.Close()
+	s.multiplexer = multiplexer
 
 	if err = multiplexer.Monitor(processor.Event{
 		Fd: serverFD,
@@ -94,8 +125,36 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 		return err
 	}
 
+	if err = syscall.Pipe(s.wakeupFDs[:]); err != nil {
+		log.Println(err)
+		return err
+	}
+	defer syscall.Close(s.wakeupFDs[0])
+	defer syscall.Close(s.wakeupFDs[1])
+	if err = syscall.SetNonblock(s.wakeupFDs[1], true); err != nil {
+		log.Println(err)
+		return err
+	}
+	if err = multiplexer.Monitor(processor.Event{
+		Fd: s.wakeupFDs[0],
+		Op: processor.OperationRead,
+	}); err != nil {
+		log.Println(err)
+		return err
+	}
+	s.wakeupWriteFD.Store(int32(s.wakeupFDs[1]))
+	// runs before the pipe is closed
+	defer s.wakeupWriteFD.Store(0)
+
+	// runs before the multiplexer is closed
+	defer s.freeAllClients()
+
+	s.cronPeriod = time.Second / time.Duration(min(max(config.Hz, 1), 500))
+	s.AddRepeatingTimer(s.cronPeriod, s.serverCron)
+
 	for atomic.LoadInt32(&eStatus) != constants.EngineStatusShuttingDown {
-		events, err = multiplexer.Check()
+		s.beforeSleep()
+		events, err = multiplexer.Check(s.timers.Timeout(time.Now()))
 		if err != nil {
 			continue
 		}
@@ -108,9 +167,6 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 
 		for _, event := range events {
 			if event.Fd == serverFD {
-				clientNum++
-				log.Printf("new client connected: id=%d\n", clientNum)
-
 				// accept new connection
 				connFd, _, err := syscall.Accept(serverFD)
 				if err != nil {
@@ -119,7 +175,9 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 				}
 
 				if err = syscall.SetNonblock(connFd, true); err != nil {
-					return err
+					log.Println(err)
+					syscall.Close(connFd)
+					continue
 				}
 
 				if err = multiplexer.Monitor(processor.Event{
@@ -127,30 +185,236 @@ func (s *Server) RunAsyncTCPServer(wg *sync.WaitGroup) error {
 					Op: processor.OperationRead,
 				}); err != nil {
 					log.Println(err)
-					return err
+					syscall.Close(connFd)
+					continue
 				}
+				s.clients[connFd] = newClient(connFd)
+				log.Printf("new client connected: fd=%d, clients=%d\n", connFd, len(s.clients))
+			} else if event.Fd == s.wakeupFDs[0] {
+				// only used to interrupt Check, the loop condition does the rest
+				var buf [64]byte
+				syscall.Read(s.wakeupFDs[0], buf[:])
 			} else {
-				// the client FD is ready for reading, means an existing client is sending us a message
-				comm := core.FDCommand{
-					Fd: event.Fd,
-				}
-				cmd, err := readCommandFD(event.Fd)
-				if err != nil {
-					syscall.Close(event.Fd)
-					clientNum--
-					log.Println("client quit")
-					atomic.SwapInt32(&eStatus, constants.EngineStatusWaiting)
+				c, ok := s.clients[event.Fd]
+				if !ok {
 					continue
 				}
-				responseRw(cmd, comm)
+				if event.Op == processor.OperationError {
+					// the peer hung up or the socket failed, there is nobody to reply to
+					c.closeASAP = true
+				}
+				if event.Op == processor.OperationWrite || event.Op == processor.OperationReadWrite {
+					// the socket can take the rest of a pending reply
+					s.writeToClient(c)
+				}
+				if event.Op == processor.OperationRead || event.Op == processor.OperationReadWrite {
+					// the client FD is ready for reading, means an existing client is sending us a message
+					s.readFromClient(c)
+				}
+				if c.closeASAP {
+					s.freeClient(c)
+				}
 			}
-			atomic.SwapInt32(&eStatus, constants.EngineStatusWaiting)
 		}
+		s.timers.Process(time.Now())
+		atomic.SwapInt32(&eStatus, constants.EngineStatusWaiting)
 	}
 
 	return nil
 }
 
+// AddTimer registers callback to run once in the event loop after delay.
+// It returns an id that can be passed to RemoveTimer.
+func (s *Server) AddTimer(delay time.Duration, callback func(now time.Time)) int64 {
+	return s.timers.AddOnce(delay, callback)
+}
+
+// AddRepeatingTimer registers callback to run in the event loop every period.
+func (s *Server) AddRepeatingTimer(period time.Duration, callback func(now time.Time)) int64 {
+	return s.timers.AddRepeating(period, callback)
+}
+
+// RemoveTimer cancels a timer registered with AddTimer or AddRepeatingTimer.
+func (s *Server) RemoveTimer(id int64) bool {
+	return s.timers.Remove(id)
+}
+
+// serverCron runs the periodic background tasks, config.Hz times per second.
+func (s *Server) serverCron(now time.Time) {
+	core.ActiveExpireCycle(s.cronPeriod * activeExpireSlowCyclePercent / 100)
+	core.ActiveExpireHashFields(activeExpireHashFieldsTimeLimit)
+	core.ActiveRehash(activeRehashTimeLimit)
+	s.clientsCron(now)
+}
+
+// clientsCron disconnects the clients that stayed over the soft output limit for too long,
+// a client that stopped reading would otherwise never be checked again.
+func (s *Server) clientsCron(now time.Time) {
+	for _, c := range s.clients {
+		if len(c.reply) > 0 && c.outputLimitReached(now) {
+			log.Printf("client fd=%d exceeded the output buffer limits, closing it\n", c.fd)
+			s.freeClient(c)
+		}
+	}
+}
+
+// beforeSleep runs the work due before the event loop waits for the next events
+func (s *Server) beforeSleep() {
+	for len(s.unblockedClients) > 0 {
+		clients := s.unblockedClients
+		s.unblockedClients = nil
+		for _, c := range clients {
+			if s.clients[c.fd] != c {
+				// disconnected in the meantime
+				continue
+			}
+			// the reply of the blocking command, then whatever was pipelined after it
+			s.processInputAndReply(c)
+			if c.closeASAP {
+				s.freeClient(c)
+			}
+		}
+	}
+	core.ActiveExpireCycle(activeExpireFastCycleDuration)
+}
+
+// freeClient stops monitoring the client, closes its socket and removes it from the client table.
+func (s *Server) freeClient(c *client) {
+	core.UnblockClient(c)
+	if c.blockTimerID != 0 {
+		s.RemoveTimer(c.blockTimerID)
+	}
+	if err := s.multiplexer.Unmonitor(c.fd); err != nil {
+		log.Println(err)
+	}
+	syscall.Close(c.fd)
+	delete(s.clients, c.fd)
+	log.Printf("client quit: fd=%d, clients=%d\n", c.fd, len(s.clients))
+}
+
+// freeAllClients disconnects every client, pending output is sent on a best effort basis.
+func (s *Server) freeAllClients() {
+	for _, c := range s.clients {
+		if !c.closeASAP {
+			c.flush()
+		}
+		s.freeClient(c)
+	}
+}
+
+// wakeup interrupts a blocking multiplexer Check, it is safe to call from any goroutine.
+func (s *Server) wakeup() {
+	fd := s.wakeupWriteFD.Load()
+	if fd == 0 {
+		// the loop is not started yet, it checks the engine status before its first wait
+		return
+	}
+	syscall.Write(int(fd), []byte{0})
+}
+
+// readFromClient reads the available input of a client, runs every complete command and
+// sends back the replies.
+func (s *Server) readFromClient(c *client) {
+	if err := c.readFromSocket(); err != nil {
+		if err == core.ErrProtocolQueryBufferLimit {
+			responseErrorRw(err, c)
+			s.writeToClient(c)
+		}
+		c.closeASAP = true
+		return
+	}
+	s.processInputAndReply(c)
+}
+
+// processInputAndReply runs the commands buffered for a client and sends back the replies
+func (s *Server) processInputAndReply(c *client) {
+	if err := s.processInput(c); err != nil {
+		// malformed request, tell the client why before dropping it
+		responseErrorRw(err, c)
+		s.writeToClient(c)
+		c.closeASAP = true
+		return
+	}
+	s.writeToClient(c)
+}
+
+// processInput executes, in order, every complete command buffered for this client.
+// Pipelined commands arriving in a single read are all answered in the same batch.
+// A client blocked by a command stops here, the rest of its input waits until it is unblocked.
+func (s *Server) processInput(c *client) error {
+	for !c.closeASAP && !core.IsBlocked(c) {
+		cmd, err := c.decoder.Next()
+		if err == core.ErrIncompleteFrame {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		responseRw(cmd, c)
+		if core.IsBlocked(c) {
+			s.blockClient(c)
+		}
+		s.handleClientsBlockedOnKeys()
+	}
+	return nil
+}
+
+// blockClient arms the timeout of the blocking command the client just ran
+func (s *Server) blockClient(c *client) {
+	deadline, ok := core.BlockedDeadline(c)
+	if !ok {
+		return
+	}
+	c.blockTimerID = s.AddTimer(time.Until(deadline), func(time.Time) {
+		c.blockTimerID = 0
+		if core.UnblockClientOnTimeout(c) {
+			s.unblockedClients = append(s.unblockedClients, c)
+		}
+	})
+}
+
+// handleClientsBlockedOnKeys serves the blocked clients whose keys received data
+func (s *Server) handleClientsBlockedOnKeys() {
+	for _, w := range core.HandleClientsBlockedOnKeys() {
+		c := w.(*client)
+		if c.blockTimerID != 0 {
+			s.RemoveTimer(c.blockTimerID)
+			c.blockTimerID = 0
+		}
+		s.unblockedClients = append(s.unblockedClients, c)
+	}
+}
+
+// writeToClient flushes the client output buffer. What the socket does not accept now is
+// kept and the fd is registered for write readiness until the buffer drains.
+func (s *Server) writeToClient(c *client) {
+	if c.closeASAP {
+		return
+	}
+	done, err := c.flush()
+	if err != nil {
+		c.closeASAP = true
+		return
+	}
+	if !done && c.outputLimitReached(time.Now()) {
+		log.Printf("client fd=%d exceeded the output buffer limits, closing it\n", c.fd)
+		c.closeASAP = true
+		return
+	}
+	if done == c.waitingWrite {
+		op := processor.OperationRead
+		if !done {
+			op = processor.OperationReadWrite
+		}
+		if err := s.multiplexer.Modify(processor.Event{Fd: c.fd, Op: op}); err != nil {
+			log.Println(err)
+			c.closeASAP = true
+			return
+		}
+		c.waitingWrite = !done
+	}
+}
+
 func responseRw(cmd *core.MemkvCommand, rw io.ReadWriter) {
 	err := core.EvalAndResponse(cmd, rw)
 	if err != nil {
@@ -162,21 +426,15 @@ func responseErrorRw(err error, rw io.ReadWriter) {
 	rw.Write([]byte(fmt.Sprintf("-%s%s", err, core.CRLF)))
 }
 
-func readCommandFD(fd int) (*core.MemkvCommand, error) {
-	var buf = make([]byte, 512)
-	n, err := syscall.Read(fd, buf)
-	if err != nil {
-		return nil, err
-	}
-	return core.ParseCmd(buf[:n])
-}
-
-func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
+// WaitForSignal waits for a termination signal and stops the event loop once the
+// commands being processed are done. The loop then disconnects every client.
+func (s *Server) WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
 	defer wg.Done()
 	<-signals
 
-	for atomic.LoadInt32(&eStatus) == constants.EngineStatusRunning {
+	for !atomic.CompareAndSwapInt32(&eStatus, constants.EngineStatusWaiting, constants.EngineStatusShuttingDown) {
+		runtime.Gosched()
 	}
 	log.Println("Shutting down gracefully...")
-	os.Exit(0)
+	s.wakeup()
 }
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"memkv/internal/config"
+	core "memkv/internal/core"
+	"memkv/internal/core/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer returns a server with a multiplexer but no listening socket, clients are
+// attached to it with newTestClient
+func newTestServer(t *testing.T) *Server {
+	s := NewServer("127.0.0.1", 0)
+	multiplexer, err := processor.CreateIoMultiplexer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.multiplexer = multiplexer
+	t.Cleanup(func() { multiplexer.Close() })
+	return s
+}
+
+// newTestClient connects a client to s through a socket pair and returns it with the fd of
+// the other end, which plays the remote peer
+func newTestClient(t *testing.T, s *Server) (*client, int) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range fds {
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.multiplexer.Monitor(processor.Event{Fd: fds[0], Op: processor.OperationRead}); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(fds[0])
+	s.clients[c.fd] = c
+	t.Cleanup(func() {
+		if s.clients[c.fd] == c {
+			s.freeClient(c)
+		}
+		syscall.Close(fds[1])
+	})
+	return c, fds[1]
+}
+
+// deleteKeys removes the keys a test created from the shared keyspace
+func deleteKeys(keys ...string) {
+	core.EvalAndResponse(&core.MemkvCommand{Cmd: core.CommandDel, Args: keys}, &bytes.Buffer{})
+}
+
+// peerSend writes data from the peer end, it must fit in the socket buffer
+func peerSend(t *testing.T, peer int, data string) {
+	n, err := syscall.Write(peer, []byte(data))
+	if err != nil || n != len(data) {
+		t.Fatalf("peer write: %d bytes, %v", n, err)
+	}
+}
+
+// peerReceive returns everything the peer end can read without waiting
+func peerReceive(peer int) string {
+	var res []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(peer, buf)
+		if n <= 0 || err != nil {
+			return string(res)
+		}
+		res = append(res, buf[:n]...)
+	}
+}
+
+func TestReadFromClient_QueryBufferLimitWhileBlocked(t *testing.T) {
+	defer func(limit int) { config.ClientQueryBufferLimit = limit }(config.ClientQueryBufferLimit)
+	config.ClientQueryBufferLimit = 1024
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+
+	peerSend(t, peer, "*3\r\n$5\r\nBLPOP\r\n$2\r\nqb\r\n$1\r\n0\r\n")
+	s.readFromClient(c)
+	assert.False(t, c.closeASAP)
+
+	// the blocked client does not run its input, it piles up until the limit
+	peerSend(t, peer, "*1\r\n$4\r\nPING\r\n")
+	s.readFromClient(c)
+	assert.False(t, c.closeASAP)
+	assert.Empty(t, peerReceive(peer))
+
+	peerSend(t, peer, "*2\r\n$4\r\nECHO\r\n$2000\r\n")
+	peerSend(t, peer, string(make([]byte, 1500)))
+	s.readFromClient(c)
+	assert.True(t, c.closeASAP)
+	assert.EqualValues(t, "-ERR Protocol error: query buffer limit exceeded\r\n", peerReceive(peer))
+}
+
+func TestEventLoop_HalfClosedPeerInputIsServed(t *testing.T) {
+	defer deleteKeys("hc")
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+
+	peerSend(t, peer, "*3\r\n$3\r\nSET\r\n$2\r\nhc\r\n$1\r\nv\r\n")
+	if err := syscall.Shutdown(peer, syscall.SHUT_RDWR); err != nil {
+		t.Fatal(err)
+	}
+	events, err := s.multiplexer.Check(time.Second)
+	assert.NoError(t, err)
+	assert.Contains(t, events, processor.Event{Fd: c.fd, Op: processor.OperationRead})
+
+	s.readFromClient(c)
+	s.readFromClient(c)
+	assert.True(t, c.closeASAP)
+
+	other, otherPeer := newTestClient(t, s)
+	peerSend(t, otherPeer, "*2\r\n$3\r\nGET\r\n$2\r\nhc\r\n")
+	s.readFromClient(other)
+	assert.EqualValues(t, "$1\r\nv\r\n", peerReceive(otherPeer))
+}
+
+func TestReadFromClient_Pipeline(t *testing.T) {
+	defer deleteKeys("pl")
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+
+	// three commands and the start of a fourth arrive in one read
+	peerSend(t, peer, "*4\r\n$4\r\nZADD\r\n$2\r\npl\r\n$1\r\n1\r\n$1\r\nm\r\n"+
+		"*4\r\n$7\r\nZINCRBY\r\n$2\r\npl\r\n$1\r\n2\r\n$1\r\nm\r\n"+
+		"*3\r\n$6\r\nZSCORE\r\n$2\r\npl\r\n$1\r\nm\r\n"+
+		"*3\r\n$6\r\nZSC")
+	s.readFromClient(c)
+	assert.EqualValues(t, ":1\r\n$1\r\n3\r\n$1\r\n3\r\n", peerReceive(peer))
+
+	peerSend(t, peer, "ORE\r\n$2\r\npl\r\n$1\r\nm\r\n")
+	s.readFromClient(c)
+	assert.EqualValues(t, "$1\r\n3\r\n", peerReceive(peer))
+	assert.False(t, c.closeASAP)
+}
+
+// feedClient sends data to the client in chunks small enough for the socket buffer,
+// letting the server read each of them
+func feedClient(t *testing.T, s *Server, c *client, peer int, data string) {
+	for len(data) > 0 {
+		n := min(len(data), 4096)
+		peerSend(t, peer, data[:n])
+		s.readFromClient(c)
+		data = data[n:]
+	}
+}
+
+func TestWriteToClient_ResumesOnWriteReadiness(t *testing.T) {
+	defer deleteKeys("pw")
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+	value := strings.Repeat("v", 512*1024)
+	feedClient(t, s, c, peer, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$2\r\npw\r\n$%d\r\n%s\r\n", len(value), value))
+	assert.EqualValues(t, "+OK\r\n", peerReceive(peer))
+
+	// the reply is larger than what the socket pair can hold
+	peerSend(t, peer, "*2\r\n$3\r\nGET\r\n$2\r\npw\r\n")
+	s.readFromClient(c)
+	assert.True(t, c.waitingWrite)
+	assert.NotEmpty(t, c.reply)
+
+	var received strings.Builder
+	for c.waitingWrite {
+		received.WriteString(peerReceive(peer))
+		events, err := s.multiplexer.Check(time.Second)
+		assert.NoError(t, err)
+		if !assert.Contains(t, events, processor.Event{Fd: c.fd, Op: processor.OperationWrite}) {
+			return
+		}
+		s.writeToClient(c)
+		assert.False(t, c.closeASAP)
+	}
+	received.WriteString(peerReceive(peer))
+	assert.EqualValues(t, fmt.Sprintf("$%d\r\n%s\r\n", len(value), value), received.String())
+	assert.Empty(t, c.reply)
+
+	// once drained the fd is only watched for input again
+	events, err := s.multiplexer.Check(0)
+	assert.NoError(t, err)
+	assert.NotContains(t, events, processor.Event{Fd: c.fd, Op: processor.OperationWrite})
+}
+
+func TestWriteToClient_OutputLimitClosesClient(t *testing.T) {
+	defer deleteKeys("ol")
+	defer func(hard, soft, seconds int) {
+		config.ClientOutputBufferHardLimit = hard
+		config.ClientOutputBufferSoftLimit = soft
+		config.ClientOutputBufferSoftSeconds = seconds
+	}(config.ClientOutputBufferHardLimit, config.ClientOutputBufferSoftLimit, config.ClientOutputBufferSoftSeconds)
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+	value := strings.Repeat("v", 1024*1024)
+	feedClient(t, s, c, peer, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$2\r\nol\r\n$%d\r\n%s\r\n", len(value), value))
+	assert.EqualValues(t, "+OK\r\n", peerReceive(peer))
+
+	config.ClientOutputBufferHardLimit = 64 * 1024
+	peerSend(t, peer, "*2\r\n$3\r\nGET\r\n$2\r\nol\r\n")
+	s.readFromClient(c)
+	assert.True(t, c.closeASAP)
+	assert.Empty(t, c.reply)
+
+	s.freeClient(c)
+	assert.NotContains(t, s.clients, c.fd)
+	// the fd was unmonitored before being closed, the peer only sees the connection end
+	events, err := s.multiplexer.Check(0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Empty(t, peerReceive(peer))
+}
+
+func TestWriteToClient_SoftOutputLimitClosesClient(t *testing.T) {
+	defer deleteKeys("sl")
+	defer func(soft, seconds int) {
+		config.ClientOutputBufferSoftLimit = soft
+		config.ClientOutputBufferSoftSeconds = seconds
+	}(config.ClientOutputBufferSoftLimit, config.ClientOutputBufferSoftSeconds)
+	s := newTestServer(t)
+	c, peer := newTestClient(t, s)
+	value := strings.Repeat("v", 1024*1024)
+	feedClient(t, s, c, peer, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$2\r\nsl\r\n$%d\r\n%s\r\n", len(value), value))
+	assert.EqualValues(t, "+OK\r\n", peerReceive(peer))
+
+	// the peer does not read, the pending reply stays above the soft limit
+	config.ClientOutputBufferSoftLimit = 64 * 1024
+	config.ClientOutputBufferSoftSeconds = 1
+	peerSend(t, peer, "*2\r\n$3\r\nGET\r\n$2\r\nsl\r\n")
+	s.readFromClient(c)
+	assert.False(t, c.closeASAP)
+	assert.True(t, c.waitingWrite)
+
+	assert.False(t, c.softLimitReachedAt.IsZero())
+
+	// not drained within the allowed seconds
+	c.softLimitReachedAt = c.softLimitReachedAt.Add(-2 * time.Second)
+	s.writeToClient(c)
+	assert.True(t, c.closeASAP)
+}